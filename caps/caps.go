@@ -0,0 +1,140 @@
+// Package caps limits the points awarded per receipt, per user and period and
+// per retailer and day.
+package caps
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const AnonymousUser = "anonymous"
+
+type Limits struct {
+	Receipt     int `json:"receipt,omitempty"`
+	UserDay     int `json:"userDay,omitempty"`
+	UserWeek    int `json:"userWeek,omitempty"`
+	UserMonth   int `json:"userMonth,omitempty"`
+	RetailerDay int `json:"retailerDay,omitempty"`
+}
+
+func Load(filename string) (Limits, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Limits{}, fmt.Errorf("Error reading point caps: %w", err)
+	}
+	var limits Limits
+	err2 := json.Unmarshal(data, &limits)
+	if err2 != nil {
+		return Limits{}, fmt.Errorf("Error unmarshaling point caps: %w", err2)
+	}
+	if limits.Receipt < 0 || limits.UserDay < 0 || limits.UserWeek < 0 || limits.UserMonth < 0 || limits.RetailerDay < 0 {
+		return Limits{}, fmt.Errorf("point caps cannot be negative")
+	}
+	return limits, nil
+}
+
+type bucket struct {
+	period string
+	key    string
+	limit  int
+	reason string
+}
+
+// Ledger keeps the points awarded in the current day, week and month by
+// period. Older periods are dropped as soon as a new one starts.
+type Ledger struct {
+	limits  Limits
+	mu      sync.Mutex
+	awarded map[string]map[string]int
+}
+
+// NewLedger gives nil when no limit is set, so nothing is capped.
+func NewLedger(limits Limits) *Ledger {
+	if limits == (Limits{}) {
+		return nil
+	}
+	return &Ledger{limits: limits, awarded: make(map[string]map[string]int)}
+}
+
+func periods(at time.Time) [3]string {
+	at = at.UTC()
+	year, week := at.ISOWeek()
+	return [3]string{at.Format("2006-01-02"), fmt.Sprintf("%d-W%02d", year, week), at.Format("2006-01")}
+}
+
+func (ledger *Ledger) buckets(user string, retailerID string, current [3]string) []bucket {
+	if user == "" {
+		user = AnonymousUser
+	}
+	candidates := []bucket{
+		{current[0], "user " + user, ledger.limits.UserDay, fmt.Sprintf("the daily limit of %d points for user %s", ledger.limits.UserDay, user)},
+		{current[1], "user " + user, ledger.limits.UserWeek, fmt.Sprintf("the weekly limit of %d points for user %s", ledger.limits.UserWeek, user)},
+		{current[2], "user " + user, ledger.limits.UserMonth, fmt.Sprintf("the monthly limit of %d points for user %s", ledger.limits.UserMonth, user)},
+		{current[0], "retailer " + retailerID, ledger.limits.RetailerDay, fmt.Sprintf("the daily limit of %d points for retailer %s", ledger.limits.RetailerDay, retailerID)},
+	}
+	var limited []bucket
+	for _, candidate := range candidates {
+		if candidate.limit > 0 {
+			limited = append(limited, candidate)
+		}
+	}
+	return limited
+}
+
+func (ledger *Ledger) rollOver(current [3]string) {
+	for period := range ledger.awarded {
+		if period != current[0] && period != current[1] && period != current[2] {
+			delete(ledger.awarded, period)
+		}
+	}
+	for _, period := range current {
+		if ledger.awarded[period] == nil {
+			ledger.awarded[period] = make(map[string]int)
+		}
+	}
+}
+
+// Award grants as many of the points as every limit still allows and records
+// them, all under one lock so concurrent receipts cannot overshoot a limit.
+// The reason names the tightest limit when any points were held back.
+func (ledger *Ledger) Award(user string, retailerID string, at time.Time, points int) (int, string) {
+	granted, reason := points, ""
+	if ledger.limits.Receipt > 0 && granted > ledger.limits.Receipt {
+		granted, reason = ledger.limits.Receipt, fmt.Sprintf("the limit of %d points per receipt", ledger.limits.Receipt)
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	current := periods(at)
+	ledger.rollOver(current)
+	buckets := ledger.buckets(user, retailerID, current)
+	for _, limited := range buckets {
+		if remaining := max(limited.limit-ledger.awarded[limited.period][limited.key], 0); granted > remaining {
+			granted, reason = remaining, limited.reason
+		}
+	}
+	if granted <= 0 {
+		return granted, reason
+	}
+	for _, limited := range buckets {
+		ledger.awarded[limited.period][limited.key] += granted
+	}
+	return granted, reason
+}
+
+// Refund gives back points awarded for a receipt that could not be stored.
+func (ledger *Ledger) Refund(user string, retailerID string, at time.Time, points int) {
+	if points <= 0 {
+		return
+	}
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	for _, limited := range ledger.buckets(user, retailerID, periods(at)) {
+		if awarded, exists := ledger.awarded[limited.period]; exists {
+			awarded[limited.key] = max(awarded[limited.key]-points, 0)
+		}
+	}
+}
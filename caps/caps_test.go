@@ -0,0 +1,104 @@
+package caps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var monday = time.Date(2022, 1, 3, 12, 0, 0, 0, time.UTC)
+
+func TestAwardPerReceiptAndDay(t *testing.T) {
+	ledger := NewLedger(Limits{Receipt: 100, UserDay: 150})
+	granted, reason := ledger.Award("alice", "target", monday, 120)
+	if granted != 100 || reason != "the limit of 100 points per receipt" {
+		t.Errorf("Should cap the receipt at 100 not %d (%s)", granted, reason)
+	}
+	granted2, reason2 := ledger.Award("alice", "target", monday, 80)
+	if granted2 != 50 || reason2 != "the daily limit of 150 points for user alice" {
+		t.Errorf("Should grant what is left of the day not %d (%s)", granted2, reason2)
+	}
+	granted3, _ := ledger.Award("bob", "target", monday, 80)
+	if granted3 != 80 {
+		t.Errorf("Should keep separate limits per user not %d", granted3)
+	}
+	granted4, _ := ledger.Award("alice", "target", monday.AddDate(0, 0, 1), 80)
+	if granted4 != 80 {
+		t.Errorf("Should start a new day not %d", granted4)
+	}
+}
+
+func TestAwardPerWeekMonthAndRetailer(t *testing.T) {
+	ledger := NewLedger(Limits{UserWeek: 100, UserMonth: 150, RetailerDay: 60})
+	ledger.Award("alice", "target", monday, 50)
+	granted, reason := ledger.Award("alice", "target", monday, 50)
+	if granted != 10 || !strings.Contains(reason, "retailer target") {
+		t.Errorf("Should share the retailer's daily limit not %d (%s)", granted, reason)
+	}
+	granted2, reason2 := ledger.Award("alice", "walgreens", monday.AddDate(0, 0, 2), 100)
+	if granted2 != 40 || !strings.Contains(reason2, "weekly") {
+		t.Errorf("Should hold the rest of the week back not %d (%s)", granted2, reason2)
+	}
+	granted3, reason3 := ledger.Award("alice", "walgreens", monday.AddDate(0, 0, 7), 100)
+	if granted3 != 50 || !strings.Contains(reason3, "monthly") {
+		t.Errorf("Should hold the rest of the month back not %d (%s)", granted3, reason3)
+	}
+	granted4, _ := ledger.Award("", "walgreens", monday.AddDate(0, 0, 7), 10)
+	if granted4 != 10 {
+		t.Errorf("Should count receipts without a user as anonymous not %d", granted4)
+	}
+}
+
+func TestAwardConcurrently(t *testing.T) {
+	ledger := NewLedger(Limits{UserDay: 1000})
+	var wait sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for range 100 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			granted, _ := ledger.Award("alice", "target", monday, 15)
+			mu.Lock()
+			total += granted
+			mu.Unlock()
+		}()
+	}
+	wait.Wait()
+	if total != 1000 {
+		t.Errorf("Should award exactly the daily limit not %d", total)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	ledger := NewLedger(Limits{UserDay: 100})
+	granted, _ := ledger.Award("alice", "target", monday, 100)
+	ledger.Refund("alice", "target", monday, granted)
+	granted2, _ := ledger.Award("alice", "target", monday, 100)
+	if granted2 != 100 {
+		t.Errorf("Should give refunded points back not %d", granted2)
+	}
+}
+
+func TestNewLedgerWithoutLimits(t *testing.T) {
+	if ledger := NewLedger(Limits{}); ledger != nil {
+		t.Errorf("Should not keep a ledger without limits")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "caps.json")
+	os.WriteFile(filename, []byte(`{"receipt": 500, "userDay": 1000, "retailerDay": 20000}`), 0o644)
+	limits, err := Load(filename)
+	if err != nil || limits != (Limits{Receipt: 500, UserDay: 1000, RetailerDay: 20000}) {
+		t.Errorf("Should load the limits not %+v ... %v", limits, err)
+	}
+	os.WriteFile(filename, []byte(`{"userDay": -1}`), 0o644)
+	_, err2 := Load(filename)
+	if err2 == nil {
+		t.Errorf("Should reject negative limits")
+	}
+}
@@ -0,0 +1,249 @@
+// Package client is a Go client for the receipt processor API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"receipt-processor/receipt"
+)
+
+var ErrNotFound = errors.New("not found")
+var ErrInvalidReceipt = errors.New("invalid receipt")
+
+type Item = receipt.Item
+type Receipt = receipt.Receipt
+
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+}
+
+func (apiError *APIError) Error() string {
+	if apiError.RequestID != "" {
+		return fmt.Sprintf("(%d) %s [request %s]", apiError.StatusCode, apiError.Message, apiError.RequestID)
+	}
+	return fmt.Sprintf("(%d) %s", apiError.StatusCode, apiError.Message)
+}
+
+func (apiError *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return apiError.StatusCode == http.StatusNotFound
+	case ErrInvalidReceipt:
+		return apiError.StatusCode == http.StatusBadRequest
+	}
+	return false
+}
+
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	userID       string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.retryBackoff = backoff
+	}
+}
+
+// WithUserID sends the user with every request, as the X-User-ID header the
+// per-user point caps count by. Pass the signed id when the server has a user
+// secret.
+func WithUserID(userID string) Option {
+	return func(client *Client) {
+		client.userID = userID
+	}
+}
+
+func New(baseURL string, options ...Option) *Client {
+	client := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		maxRetries:   3,
+		retryBackoff: 200 * time.Millisecond,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+func (client *Client) ProcessReceipt(ctx context.Context, submitted Receipt) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+	err := client.do(ctx, http.MethodPost, "/receipts/process", submitted, &response)
+	return response.ID, err
+}
+
+func (client *Client) GetPoints(ctx context.Context, id string) (int, error) {
+	var response struct {
+		Points int `json:"points"`
+	}
+	err := client.do(ctx, http.MethodGet, "/receipts/"+url.PathEscape(id)+"/points", nil, &response)
+	return response.Points, err
+}
+
+func (client *Client) GetBreakdown(ctx context.Context, id string) ([]string, error) {
+	var response struct {
+		Breakdown []string `json:"breakdown"`
+	}
+	err := client.do(ctx, http.MethodGet, "/receipts/"+url.PathEscape(id)+"/breakdown", nil, &response)
+	return response.Breakdown, err
+}
+
+type ExportOptions struct {
+	Format     string
+	Rows       string
+	Retailer   string
+	RetailerID string
+	From       string
+	To         string
+}
+
+func (client *Client) Export(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{"format": options.Format, "rows": options.Rows, "retailer": options.Retailer, "retailerId": options.RetailerID, "from": options.From, "to": options.To} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+"/receipts/export?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err2 := client.httpClient.Do(request)
+	if err2 != nil {
+		return nil, err2
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		data, _ := io.ReadAll(response.Body)
+		return nil, newAPIError(response, data)
+	}
+	return response.Body, nil
+}
+
+func (client *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		payload, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("Error marshaling JSON: %w", err)
+		}
+	}
+
+	backoff := client.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := client.attempt(ctx, method, path, payload, out)
+		if err == nil || attempt >= client.maxRetries || !retryable(method, err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (client *Client) attempt(ctx context.Context, method string, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, client.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if client.userID != "" {
+		request.Header.Set("X-User-ID", client.userID)
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err2 := client.httpClient.Do(request)
+	if err2 != nil {
+		return err2
+	}
+	defer response.Body.Close()
+	data, err3 := io.ReadAll(response.Body)
+	if err3 != nil {
+		return fmt.Errorf("Error reading response body: %w", err3)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return newAPIError(response, data)
+	}
+
+	err4 := json.Unmarshal(data, out)
+	if err4 != nil {
+		return fmt.Errorf("Error unmarshaling JSON: %w", err4)
+	}
+	return nil
+}
+
+func newAPIError(response *http.Response, data []byte) *APIError {
+	apiError := &APIError{StatusCode: response.StatusCode, RequestID: response.Header.Get("X-Request-ID")}
+	var errorBody struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(data, &errorBody) == nil && errorBody.Error != "" {
+		apiError.Message = errorBody.Error
+		if errorBody.RequestID != "" {
+			apiError.RequestID = errorBody.RequestID
+		}
+	} else {
+		apiError.Message = strings.TrimSpace(string(data))
+	}
+	return apiError
+}
+
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		switch apiError.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusInternalServerError:
+			return method == http.MethodGet
+		}
+		return false
+	}
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		return true
+	}
+	return method == http.MethodGet
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"receipt-processor/caps"
+	"receipt-processor/server"
+	"receipt-processor/storage"
+)
+
+var receiptExample = Receipt{
+	Retailer:     "Walgreens",
+	PurchaseDate: "2022-01-02",
+	PurchaseTime: "08:13",
+	Items: []Item{
+		{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+		{ShortDescription: "Dasani", Price: "1.40"},
+	},
+	Total: "2.65",
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	json.NewEncoder(writer).Encode(v)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /receipts/process", func(writer http.ResponseWriter, request *http.Request) {
+		var receipt Receipt
+		json.NewDecoder(request.Body).Decode(&receipt)
+		if receipt.Retailer == "" {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "Validation errors: retailer cannot be empty", "request_id": "req-1"})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]string{"id": "abc-123"})
+	})
+	mux.HandleFunc("GET /receipts/{id}/points", func(writer http.ResponseWriter, request *http.Request) {
+		if request.PathValue("id") != "abc-123" {
+			writeJSON(writer, http.StatusNotFound, map[string]string{"error": "receipt " + request.PathValue("id") + " not found"})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]int{"points": 15})
+	})
+	mux.HandleFunc("GET /receipts/{id}/breakdown", func(writer http.ResponseWriter, request *http.Request) {
+		writeJSON(writer, http.StatusOK, map[string][]string{"breakdown": {"9 points for retailer name (Walgreens)", "15 points total"}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessReceiptAndGetPoints(t *testing.T) {
+	server := newTestServer(t)
+	client := New(server.URL)
+	ctx := context.Background()
+
+	id, err := client.ProcessReceipt(ctx, receiptExample)
+	if err != nil || id != "abc-123" {
+		t.Fatalf("Should get id abc-123 not '%s' ... %v", id, err)
+	}
+	points, err2 := client.GetPoints(ctx, id)
+	if err2 != nil || points != 15 {
+		t.Errorf("Should get 15 points not %d ... %v", points, err2)
+	}
+	breakdown, err3 := client.GetBreakdown(ctx, id)
+	if err3 != nil || len(breakdown) != 2 {
+		t.Errorf("Should get 2 breakdown entries not %v ... %v", breakdown, err3)
+	}
+}
+
+func TestProcessReceiptInvalid(t *testing.T) {
+	server := newTestServer(t)
+	client := New(server.URL)
+	_, err := client.ProcessReceipt(context.Background(), Receipt{})
+	if !errors.Is(err, ErrInvalidReceipt) {
+		t.Fatalf("Should be ErrInvalidReceipt ... %v", err)
+	}
+	var apiError *APIError
+	errors.As(err, &apiError)
+	if apiError.Message != "Validation errors: retailer cannot be empty" || apiError.RequestID != "req-1" {
+		t.Errorf("Should map the error body onto APIError ... %+v", apiError)
+	}
+}
+
+func TestGetPointsNotFound(t *testing.T) {
+	server := newTestServer(t)
+	client := New(server.URL)
+	_, err := client.GetPoints(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Should be ErrNotFound ... %v", err)
+	}
+}
+
+func TestRetriesOnServiceUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if calls.Add(1) < 3 {
+			writeJSON(writer, http.StatusServiceUnavailable, map[string]string{"error": "draining"})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]int{"points": 15})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, WithRetries(3, time.Millisecond))
+	points, err := client.GetPoints(context.Background(), "abc-123")
+	if err != nil || points != 15 {
+		t.Errorf("Should get 15 points after retrying not %d ... %v", points, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Should have made 3 calls not %d", calls.Load())
+	}
+}
+
+func TestNoRetryForPostOnServerError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls.Add(1)
+		writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": "Could not store receipt"})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, WithRetries(3, time.Millisecond))
+	_, err := client.ProcessReceipt(context.Background(), receiptExample)
+	if err == nil {
+		t.Errorf("Should have an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Should not retry a POST after a 500 but made %d calls", calls.Load())
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetPoints(ctx, "abc-123")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Should stop with context.DeadlineExceeded ... %v", err)
+	}
+}
+
+func TestAgainstReceiptServer(t *testing.T) {
+	receiptServer := server.New(storage.NewMemoryStore(), server.WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	httpServer := httptest.NewServer(receiptServer.Handler())
+	defer httpServer.Close()
+
+	client := New(httpServer.URL)
+	ctx := context.Background()
+	id, err := client.ProcessReceipt(ctx, receiptExample)
+	if err != nil {
+		t.Fatalf("Should process the receipt ... %v", err)
+	}
+	points, err2 := client.GetPoints(ctx, id)
+	if err2 != nil || points != 15 {
+		t.Errorf("Should get 15 points not %d ... %v", points, err2)
+	}
+	_, err3 := client.GetBreakdown(ctx, "missing")
+	if !errors.Is(err3, ErrNotFound) {
+		t.Errorf("Should be ErrNotFound ... %v", err3)
+	}
+}
+
+func TestProcessReceiptAsUser(t *testing.T) {
+	receiptServer := server.New(storage.NewMemoryStore(), server.WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))), server.WithUserSecret("secret"), server.WithCaps(caps.Limits{UserDay: 20}))
+	httpServer := httptest.NewServer(receiptServer.Handler())
+	defer httpServer.Close()
+
+	ctx := context.Background()
+	alice := New(httpServer.URL, WithUserID(server.SignUserID("secret", "alice")))
+	expected := []int{15, 5}
+	for _, want := range expected {
+		id, err := alice.ProcessReceipt(ctx, receiptExample)
+		if err != nil {
+			t.Fatalf("Should process the receipt as alice ... %v", err)
+		}
+		points, _ := alice.GetPoints(ctx, id)
+		if points != want {
+			t.Errorf("Should count alice's points against her cap, %d not %d", want, points)
+		}
+	}
+
+	_, err2 := New(httpServer.URL, WithUserID("mallory")).ProcessReceipt(ctx, receiptExample)
+	var apiError *APIError
+	if !errors.As(err2, &apiError) || apiError.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Should reject an unsigned user ... %v", err2)
+	}
+}
+
+func TestExport(t *testing.T) {
+	receiptServer := server.New(storage.NewMemoryStore(), server.WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	httpServer := httptest.NewServer(receiptServer.Handler())
+	defer httpServer.Close()
+
+	client := New(httpServer.URL)
+	ctx := context.Background()
+	id, _ := client.ProcessReceipt(ctx, receiptExample)
+	body, err := client.Export(ctx, ExportOptions{Format: "jsonl", Rows: "item", Retailer: "walgreens"})
+	if err != nil {
+		t.Fatalf("Should export ... %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if !strings.HasPrefix(string(data), `{"id":"`+id+`","retailer":"Walgreens"`) || strings.Count(string(data), "\n") != 2 {
+		t.Errorf("Should export 2 item rows ... %s", data)
+	}
+
+	_, err2 := client.Export(ctx, ExportOptions{Format: "xlsx"})
+	var apiError *APIError
+	if !errors.As(err2, &apiError) || apiError.StatusCode != http.StatusBadRequest || !strings.Contains(apiError.Message, "format must be csv or jsonl") {
+		t.Errorf("Should return the API error ... %v", err2)
+	}
+}
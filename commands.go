@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"receipt-processor/client"
+	"receipt-processor/exporter"
+	"receipt-processor/importer"
+)
+
+func runCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:], stdout, stderr)
+	case "export":
+		return runExport(args[1:], stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "unknown command %q, available commands: import, export\n", args[0])
+	return 2
+}
+
+// runImport validates with the same options as the server, read from the
+// environment, with -tolerance and -punctuation overriding them.
+func runImport(args []string, stdout io.Writer, stderr io.Writer) int {
+	validation, err := validationOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	serverURL := flags.String("server", "", "submit valid receipts to the receipt processor at this URL")
+	tolerance := flags.Int("tolerance", validation.ToleranceCents, "allow subtotals and totals to be off by this many cents")
+	punctuation := flags.String("punctuation", validation.Punctuation, "extra punctuation allowed in retailers and descriptions")
+	user := flags.String("user", "", "X-User-ID to submit the receipts with, signed when the server has USER_ID_SECRET")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: receipt-processor import [-server URL] [-user ID] [-tolerance CENTS] [-punctuation CHARS] FILE.csv")
+		flags.PrintDefaults()
+	}
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err2 := os.Open(flags.Arg(0))
+	if err2 != nil {
+		fmt.Fprintln(stderr, err2)
+		return 1
+	}
+	defer file.Close()
+	validation.ToleranceCents, validation.Punctuation = *tolerance, *punctuation
+	result, err3 := importer.ImportCSV(file, validation)
+	if err3 != nil {
+		fmt.Fprintln(stderr, err3)
+		return 1
+	}
+
+	for _, rowError := range result.Errors {
+		fmt.Fprintf(stderr, "row %d: %s\n", rowError.Row, rowError.Message)
+	}
+	var receiptClient *client.Client
+	if *serverURL != "" {
+		receiptClient = client.New(*serverURL, client.WithUserID(*user))
+	}
+	failed := len(result.Errors) > 0
+	for _, imported := range result.Receipts {
+		rows := fmt.Sprintf("row %d", imported.Rows[0])
+		if len(imported.Rows) > 1 {
+			rows = fmt.Sprintf("rows %d-%d", imported.Rows[0], imported.Rows[len(imported.Rows)-1])
+		}
+		if receiptClient == nil {
+			fmt.Fprintf(stdout, "%s: valid receipt from %s\n", rows, imported.Receipt.Retailer)
+			continue
+		}
+		id, err4 := receiptClient.ProcessReceipt(context.Background(), imported.Receipt)
+		if err4 != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", rows, err4)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", rows, id)
+	}
+	fmt.Fprintf(stdout, "%d receipt(s) imported, %d row error(s)\n", len(result.Receipts), len(result.Errors))
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	serverURL := flags.String("server", "http://localhost:8080", "the receipt processor to export from")
+	format := flags.String("format", "csv", "csv, jsonl or parquet")
+	rows := flags.String("rows", exporter.RowsPerReceipt, "one row per receipt or per item")
+	retailer := flags.String("retailer", "", "only export receipts from this retailer")
+	retailerID := flags.String("retailer-id", "", "only export receipts with this canonical retailer id")
+	from := flags.String("from", "", "only export receipts purchased on or after this YYYY-MM-DD date")
+	to := flags.String("to", "", "only export receipts purchased on or before this YYYY-MM-DD date")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: receipt-processor export [flags]")
+		flags.PrintDefaults()
+	}
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if *format != "csv" && *format != "jsonl" && *format != "parquet" {
+		fmt.Fprintf(stderr, "format must be csv, jsonl or parquet (%s)\n", *format)
+		return 2
+	}
+	if *format == "parquet" && *output == "" {
+		fmt.Fprintln(stderr, "parquet exports need an output file (-o)")
+		return 2
+	}
+
+	options := client.ExportOptions{Format: *format, Rows: *rows, Retailer: *retailer, RetailerID: *retailerID, From: *from, To: *to}
+	if *format == "parquet" {
+		options.Format = "jsonl"
+	}
+	body, err := client.New(*serverURL).Export(context.Background(), options)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer body.Close()
+
+	destination := stdout
+	var file *os.File
+	if *output != "" {
+		var err2 error
+		file, err2 = os.Create(*output)
+		if err2 != nil {
+			fmt.Fprintln(stderr, err2)
+			return 1
+		}
+		defer file.Close()
+		destination = file
+	}
+
+	count := 0
+	var err3 error
+	if *format != "parquet" {
+		_, err3 = io.Copy(destination, body)
+	} else {
+		parquetWriter := exporter.NewParquetWriter(destination)
+		err3 = exporter.ReadJSONL(body, func(record exporter.Record) error {
+			count++
+			return parquetWriter.Write(record)
+		})
+		if err3 == nil {
+			err3 = parquetWriter.Close()
+		}
+	}
+	if err3 == nil && file != nil {
+		err3 = file.Close()
+	}
+	if err3 != nil {
+		fmt.Fprintln(stderr, err3)
+		return 1
+	}
+	if *format == "parquet" {
+		fmt.Fprintf(stderr, "%d row(s) written to %s\n", count, *output)
+	}
+	return 0
+}
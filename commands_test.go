@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"receipt-processor/server"
+	"receipt-processor/storage"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "receipts.csv")
+	os.WriteFile(filename, []byte(content), 0o644)
+	return filename
+}
+
+func TestImportCommand(t *testing.T) {
+	filename := writeCSV(t, `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25
+Walgreens,2022-01-02,08:13,2.65,Dasani,1.40
+Target,2022-01-02,13:13,1.25,,1.25
+`)
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"import", filename}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("Should exit 1 when rows have errors not %d", code)
+	}
+	if !strings.Contains(stdout.String(), "rows 2-3: valid receipt from Walgreens") {
+		t.Errorf("Should list the valid receipt ... %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "row 4: shortDescription cannot be empty") {
+		t.Errorf("Should report the row error ... %s", stderr.String())
+	}
+}
+
+func TestImportCommandSubmits(t *testing.T) {
+	store := storage.NewMemoryStore()
+	receiptServer := server.New(store, server.WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	httpServer := httptest.NewServer(receiptServer.Handler())
+	defer httpServer.Close()
+
+	filename := writeCSV(t, `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25
+`)
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"import", "-server", httpServer.URL, filename}, &stdout, &stderr)
+	if code != 0 {
+		t.Errorf("Should exit 0 not %d ... %s", code, stderr.String())
+	}
+	count, _ := store.Count(context.Background())
+	if count != 1 {
+		t.Errorf("Should have stored 1 receipt not %d", count)
+	}
+	if !strings.Contains(stdout.String(), "1 receipt(s) imported, 0 row error(s)") {
+		t.Errorf("Should print a summary ... %s", stdout.String())
+	}
+}
+
+func TestImportCommandUsesServerValidation(t *testing.T) {
+	filename := writeCSV(t, `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Trader Joe's,2022-01-02,13:13,1.26,Bananas,1.25
+`)
+	t.Setenv("ALLOWED_PUNCTUATION", "'")
+	t.Setenv("TOTAL_TOLERANCE_CENTS", "1")
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"import", filename}, &stdout, &stderr); code != 0 {
+		t.Errorf("Should accept the receipt with the server's validation settings not %d ... %s", code, stderr.String())
+	}
+	var stdout2, stderr2 bytes.Buffer
+	if code := runCommand([]string{"import", "-tolerance", "0", filename}, &stdout2, &stderr2); code != 1 {
+		t.Errorf("Should let -tolerance override TOTAL_TOLERANCE_CENTS not %d", code)
+	}
+
+	t.Setenv("DEFAULT_TIME_ZONE", "Mars/Olympus_Mons")
+	var stdout3, stderr3 bytes.Buffer
+	if code := runCommand([]string{"import", filename}, &stdout3, &stderr3); code != 1 || !strings.Contains(stderr3.String(), "unknown time zone") {
+		t.Errorf("Should fail on time zones the server would reject not %d ... %s", code, stderr3.String())
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if runCommand([]string{"nope"}, &stdout, &stderr) != 2 {
+		t.Errorf("Should exit 2 for an unknown command")
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	receiptServer := server.New(storage.NewMemoryStore(), server.WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	httpServer := httptest.NewServer(receiptServer.Handler())
+	defer httpServer.Close()
+	filename := writeCSV(t, `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25
+Walgreens,2022-01-02,08:13,1.40,Dasani,1.40
+`)
+	runCommand([]string{"import", "-server", httpServer.URL, filename}, io.Discard, io.Discard)
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"export", "-server", httpServer.URL, "-retailer", "target"}, &stdout, &stderr)
+	if code != 0 || strings.Count(stdout.String(), "\n") != 2 || !strings.Contains(stdout.String(), ",Target,") {
+		t.Errorf("(%d) Should export the Target receipt as CSV ... %s %s", code, stdout.String(), stderr.String())
+	}
+
+	output := filepath.Join(t.TempDir(), "receipts.parquet")
+	code2 := runCommand([]string{"export", "-server", httpServer.URL, "-format", "parquet", "-rows", "item", "-o", output}, &stdout, &stderr)
+	if code2 != 0 || !strings.Contains(stderr.String(), "2 row(s) written") {
+		t.Errorf("(%d) Should write 2 parquet rows ... %s", code2, stderr.String())
+	}
+	data, _ := os.ReadFile(output)
+	if !bytes.HasPrefix(data, []byte("PAR1")) {
+		t.Errorf("Should write a parquet file")
+	}
+
+	if runCommand([]string{"export", "-format", "parquet"}, &stdout, &stderr) != 2 {
+		t.Errorf("Should require -o for parquet exports")
+	}
+}
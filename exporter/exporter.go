@@ -0,0 +1,229 @@
+// Package exporter writes stored receipts and their points as CSV, JSON Lines
+// or Parquet rows.
+package exporter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"receipt-processor/receipt"
+)
+
+const (
+	RowsPerReceipt = "receipt"
+	RowsPerItem    = "item"
+)
+
+type Record struct {
+	ID               string         `json:"id" parquet:"id"`
+	Retailer         string         `json:"retailer" parquet:"retailer"`
+	RetailerID       string         `json:"retailerId,omitempty" parquet:"retailer_id,optional"`
+	PurchaseDate     string         `json:"purchaseDate" parquet:"purchase_date"`
+	PurchaseTime     string         `json:"purchaseTime" parquet:"purchase_time"`
+	PurchasedAt      string         `json:"purchasedAt,omitempty" parquet:"purchased_at,optional"`
+	Total            string         `json:"total" parquet:"total"`
+	Currency         string         `json:"currency" parquet:"currency"`
+	Items            int            `json:"items,omitempty" parquet:"items,optional"`
+	Item             *int           `json:"item,omitempty" parquet:"item,optional"`
+	ShortDescription string         `json:"shortDescription,omitempty" parquet:"short_description,optional"`
+	Price            string         `json:"price,omitempty" parquet:"price,optional"`
+	Quantity         string         `json:"quantity,omitempty" parquet:"quantity,optional"`
+	UnitPrice        string         `json:"unitPrice,omitempty" parquet:"unit_price,optional"`
+	Discounts        string         `json:"discounts,omitempty" parquet:"discounts,optional"`
+	Subtotal         string         `json:"subtotal,omitempty" parquet:"subtotal,optional"`
+	Tax              string         `json:"tax,omitempty" parquet:"tax,optional"`
+	Tip              string         `json:"tip,omitempty" parquet:"tip,optional"`
+	Points           int            `json:"points" parquet:"points"`
+	Rules            map[string]int `json:"rules" parquet:"rules"`
+}
+
+type Writer interface {
+	Write(record Record) error
+	Close() error
+}
+
+// RuleNames lists the rule columns for the ruleset. Receipts are scored with
+// the campaigns recorded on them, so the campaign columns come from the
+// campaigns of the exported receipts, including ones deleted since, and the
+// cap column is only there when some of them were stored with a cap.
+func RuleNames(ruleset *receipt.Ruleset, campaigns []receipt.Campaign, capped bool, rows string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, rule := range ruleset.Rules {
+		if seen[rule.Name] || (rows == RowsPerItem && rule.Item == nil) {
+			continue
+		}
+		seen[rule.Name] = true
+		names = append(names, rule.Name)
+	}
+	for _, bonus := range ruleset.BonusRules() {
+		if rows == RowsPerItem && !bonus.PerItem() {
+			continue
+		}
+		names = append(names, bonus.RuleName())
+	}
+	for _, campaign := range campaigns {
+		if seen[campaign.RuleName()] || (rows == RowsPerItem && !campaign.PerItem()) {
+			continue
+		}
+		seen[campaign.RuleName()] = true
+		names = append(names, campaign.RuleName())
+	}
+	if capped && rows != RowsPerItem {
+		names = append(names, receipt.CapRule)
+	}
+	return names
+}
+
+func Records(ctx context.Context, ruleset *receipt.Ruleset, id string, stored receipt.Receipt, rows string) []Record {
+	results := ruleset.Evaluate(ctx, &stored)
+	base := Record{
+		ID:           id,
+		Retailer:     stored.Retailer,
+		RetailerID:   stored.RetailerID,
+		PurchaseDate: stored.PurchaseDate,
+		PurchaseTime: stored.PurchaseTime,
+		PurchasedAt:  stored.PurchasedAt,
+		Total:        stored.Total,
+		Currency:     stored.Currency,
+	}
+	if base.Currency == "" {
+		base.Currency = receipt.DefaultCurrency
+	}
+
+	if rows != RowsPerItem {
+		record := base
+		record.Items = len(stored.Items)
+		record.Discounts = discountTotal(stored)
+		record.Subtotal = stored.Subtotal
+		record.Tax = stored.Tax
+		record.Tip = stored.Tip
+		record.Rules = make(map[string]int)
+		for _, result := range results {
+			record.Points += result.Points
+			record.Rules[result.Rule] += result.Points
+		}
+		return []Record{record}
+	}
+
+	records := make([]Record, len(stored.Items))
+	for index, item := range stored.Items {
+		records[index] = base
+		records[index].Item = &index
+		records[index].ShortDescription = item.ShortDescription
+		records[index].Price = item.Price
+		records[index].Quantity = item.Quantity
+		records[index].UnitPrice = item.UnitPrice
+		records[index].Rules = make(map[string]int)
+	}
+	for _, result := range results {
+		if result.Item == nil {
+			continue
+		}
+		record := &records[*result.Item]
+		record.Points += result.Points
+		record.Rules[result.Rule] += result.Points
+	}
+	return records
+}
+
+func discountTotal(stored receipt.Receipt) string {
+	if len(stored.Discounts) == 0 {
+		return ""
+	}
+	currency, _ := receipt.LookupCurrency(stored.Currency)
+	total := 0
+	for _, discount := range stored.Discounts {
+		total += currency.Minor(discount.Amount)
+	}
+	return currency.Format(total)
+}
+
+type CSVWriter struct {
+	writer *csv.Writer
+	rules  []string
+	rows   string
+}
+
+func NewCSVWriter(writer io.Writer, rules []string, rows string) (*CSVWriter, error) {
+	csvWriter := &CSVWriter{writer: csv.NewWriter(writer), rules: rules, rows: rows}
+	header := []string{"id", "retailer", "retailerId", "purchaseDate", "purchaseTime", "purchasedAt", "total", "currency"}
+	if rows == RowsPerItem {
+		header = append(header, "item", "shortDescription", "price", "quantity", "unitPrice")
+	} else {
+		header = append(header, "items", "discounts", "subtotal", "tax", "tip")
+	}
+	header = append(header, "points")
+	for _, rule := range rules {
+		header = append(header, "rule_"+rule)
+	}
+	err := csvWriter.writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return csvWriter, nil
+}
+
+func (csvWriter *CSVWriter) Write(record Record) error {
+	row := []string{record.ID, record.Retailer, record.RetailerID, record.PurchaseDate, record.PurchaseTime, record.PurchasedAt, record.Total, record.Currency}
+	if csvWriter.rows == RowsPerItem {
+		item := ""
+		if record.Item != nil {
+			item = strconv.Itoa(*record.Item)
+		}
+		row = append(row, item, record.ShortDescription, record.Price, record.Quantity, record.UnitPrice)
+	} else {
+		row = append(row, strconv.Itoa(record.Items), record.Discounts, record.Subtotal, record.Tax, record.Tip)
+	}
+	row = append(row, strconv.Itoa(record.Points))
+	for _, rule := range csvWriter.rules {
+		row = append(row, strconv.Itoa(record.Rules[rule]))
+	}
+	err := csvWriter.writer.Write(row)
+	if err != nil {
+		return err
+	}
+	csvWriter.writer.Flush()
+	return csvWriter.writer.Error()
+}
+
+func (csvWriter *CSVWriter) Close() error {
+	csvWriter.writer.Flush()
+	return csvWriter.writer.Error()
+}
+
+type JSONLWriter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONLWriter(writer io.Writer) *JSONLWriter {
+	return &JSONLWriter{encoder: json.NewEncoder(writer)}
+}
+
+func (jsonlWriter *JSONLWriter) Write(record Record) error {
+	return jsonlWriter.encoder.Encode(record)
+}
+
+func (jsonlWriter *JSONLWriter) Close() error {
+	return nil
+}
+
+func ReadJSONL(reader io.Reader, each func(record Record) error) error {
+	decoder := json.NewDecoder(reader)
+	for line := 1; decoder.More(); line++ {
+		var record Record
+		err := decoder.Decode(&record)
+		if err != nil {
+			return fmt.Errorf("Error reading record %d: %w", line, err)
+		}
+		err2 := each(record)
+		if err2 != nil {
+			return err2
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"receipt-processor/receipt"
+)
+
+var walgreens = receipt.Receipt{
+	Retailer:     "Walgreens",
+	PurchaseDate: "2022-01-02",
+	PurchaseTime: "08:13",
+	Total:        "2.65",
+	Items: []receipt.Item{
+		{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+		{ShortDescription: "Dasani", Price: "1.40"},
+	},
+}
+
+func TestRecordsPerReceipt(t *testing.T) {
+	records := Records(context.Background(), receipt.StandardRuleset, "abc", walgreens, RowsPerReceipt)
+	if len(records) != 1 {
+		t.Fatalf("Should have 1 record not %d", len(records))
+	}
+	record := records[0]
+	if record.ID != "abc" || record.Items != 2 || record.Points != 15 {
+		t.Errorf("Should have id abc, 2 items and 15 points not %+v", record)
+	}
+	if record.Discounts != "" {
+		t.Errorf("Should have no discounts not %s", record.Discounts)
+	}
+	if record.Rules["retailer_name"] != 9 || record.Rules["num_items"] != 5 || record.Rules["item_description"] != 1 {
+		t.Errorf("Should have per-rule points not %v", record.Rules)
+	}
+}
+
+func TestRecordsPerItem(t *testing.T) {
+	records := Records(context.Background(), receipt.StandardRuleset, "abc", walgreens, RowsPerItem)
+	if len(records) != 2 {
+		t.Fatalf("Should have 2 records not %d", len(records))
+	}
+	if *records[0].Item != 0 || records[0].ShortDescription != "Pepsi - 12-oz" || records[0].Points != 0 {
+		t.Errorf("Should have the first item with 0 points not %+v", records[0])
+	}
+	if *records[1].Item != 1 || records[1].Price != "1.40" || records[1].Points != 1 || records[1].Rules["item_description"] != 1 {
+		t.Errorf("Should have the second item with 1 point not %+v", records[1])
+	}
+	if _, exists := records[1].Rules["retailer_name"]; exists {
+		t.Errorf("Should not have receipt rules on item rows not %v", records[1].Rules)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	rules := RuleNames(receipt.StandardRuleset, nil, false, RowsPerItem)
+	csvWriter, _ := NewCSVWriter(&buffer, rules, RowsPerItem)
+	for _, record := range Records(context.Background(), receipt.StandardRuleset, "abc", walgreens, RowsPerItem) {
+		csvWriter.Write(record)
+	}
+	csvWriter.Close()
+	expected := `id,retailer,retailerId,purchaseDate,purchaseTime,purchasedAt,total,currency,item,shortDescription,price,quantity,unitPrice,points,rule_item_description,rule_item_title
+abc,Walgreens,,2022-01-02,08:13,,2.65,USD,0,Pepsi - 12-oz,1.25,,,0,0,0
+abc,Walgreens,,2022-01-02,08:13,,2.65,USD,1,Dasani,1.40,,,1,1,0
+`
+	if buffer.String() != expected {
+		t.Errorf("Should write item rows\n%s\nnot\n%s", expected, buffer.String())
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	jsonlWriter := NewJSONLWriter(&buffer)
+	for _, record := range Records(context.Background(), receipt.StandardRuleset, "abc", walgreens, RowsPerItem) {
+		jsonlWriter.Write(record)
+	}
+	if !strings.HasPrefix(buffer.String(), `{"id":"abc","retailer":"Walgreens"`) {
+		t.Errorf("Should write one JSON object per line ... %s", buffer.String())
+	}
+	var read []Record
+	err := ReadJSONL(&buffer, func(record Record) error {
+		read = append(read, record)
+		return nil
+	})
+	if err != nil || len(read) != 2 || *read[1].Item != 1 || read[1].Rules["item_description"] != 1 {
+		t.Errorf("Should read back the records ... %v %+v", err, read)
+	}
+}
+
+func TestRecordsWithQuantityAndDiscounts(t *testing.T) {
+	discounted := walgreens
+	discounted.Items = []receipt.Item{{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: "0.75"}}
+	discounted.Discounts = []receipt.Discount{{Description: "Coupon", Amount: "0.25"}, {Description: "Member", Amount: "0.80"}}
+	discounted.Total = "1.20"
+	records := Records(context.Background(), receipt.StandardRuleset, "abc", discounted, RowsPerReceipt)
+	if records[0].Discounts != "1.05" {
+		t.Errorf("Should total the discounts not %s", records[0].Discounts)
+	}
+	items := Records(context.Background(), receipt.StandardRuleset, "abc", discounted, RowsPerItem)
+	if items[0].Quantity != "3" || items[0].UnitPrice != "0.75" {
+		t.Errorf("Should include quantity and unit price not %+v", items[0])
+	}
+}
+
+func TestRuleNamesWithBonuses(t *testing.T) {
+	bonuses := &receipt.Bonuses{Rules: []receipt.BonusRule{
+		{ID: "walgreens", Retailer: "Walgreens", Points: 50},
+		{ID: "drinks", Item: "pepsi|dasani", Points: 5},
+	}}
+	bonuses.Compile()
+	ruleset := receipt.StandardRuleset.WithBonuses(bonuses)
+
+	perReceipt := RuleNames(ruleset, nil, true, RowsPerReceipt)
+	if strings.Join(perReceipt[len(perReceipt)-3:], ",") != "bonus_walgreens,bonus_drinks,points_cap" {
+		t.Errorf("Should list the bonus rules after the ruleset's own and the cap last not %v", perReceipt)
+	}
+	perItem := RuleNames(ruleset, nil, true, RowsPerItem)
+	if strings.Join(perItem, ",") != "item_description,item_title,bonus_drinks" {
+		t.Errorf("Should list only item bonuses for item rows not %v", perItem)
+	}
+
+	records := Records(context.Background(), ruleset, "abc", walgreens, RowsPerItem)
+	if records[0].Rules["bonus_drinks"] != 5 || records[1].Rules["bonus_drinks"] != 5 {
+		t.Errorf("Should put item bonuses on their rows not %+v", records)
+	}
+}
@@ -0,0 +1,24 @@
+package exporter
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type ParquetWriter struct {
+	writer *parquet.GenericWriter[Record]
+}
+
+func NewParquetWriter(writer io.Writer) *ParquetWriter {
+	return &ParquetWriter{writer: parquet.NewGenericWriter[Record](writer)}
+}
+
+func (parquetWriter *ParquetWriter) Write(record Record) error {
+	_, err := parquetWriter.writer.Write([]Record{record})
+	return err
+}
+
+func (parquetWriter *ParquetWriter) Close() error {
+	return parquetWriter.writer.Close()
+}
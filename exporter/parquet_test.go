@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"receipt-processor/receipt"
+)
+
+func TestParquetWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	parquetWriter := NewParquetWriter(&buffer)
+	for _, record := range Records(context.Background(), receipt.StandardRuleset, "abc", walgreens, RowsPerItem) {
+		err := parquetWriter.Write(record)
+		if err != nil {
+			t.Fatalf("Should write the record ... %s", err)
+		}
+	}
+	err2 := parquetWriter.Close()
+	if err2 != nil {
+		t.Fatalf("Should close the file ... %s", err2)
+	}
+
+	read, err3 := parquet.Read[Record](bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err3 != nil || len(read) != 2 {
+		t.Fatalf("Should read back 2 rows ... %v %+v", err3, read)
+	}
+	if read[1].ShortDescription != "Dasani" || *read[1].Item != 1 || read[1].Rules["item_description"] != 1 {
+		t.Errorf("Should read back the item row not %+v", read[1])
+	}
+}
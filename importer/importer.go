@@ -0,0 +1,160 @@
+// Package importer turns CSV exports with one row per item into receipts.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"receipt-processor/receipt"
+)
+
+var requiredColumns = []string{"retailer", "purchaseDate", "purchaseTime", "total", "shortDescription", "price"}
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportedReceipt struct {
+	Receipt receipt.Receipt `json:"receipt"`
+	Rows    []int           `json:"rows"`
+}
+
+type Result struct {
+	Receipts []ImportedReceipt `json:"receipts"`
+	Errors   []RowError        `json:"errors"`
+}
+
+type group struct {
+	key          string
+	receipt      receipt.Receipt
+	rows         []int
+	itemRows     []int
+	discountRows []int
+}
+
+func ImportCSV(reader io.Reader, options receipt.ValidationOptions) (*Result, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for index, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	var missing []string
+	for _, name := range requiredColumns {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header is missing column(s): %s", strings.Join(missing, ", "))
+	}
+	receiptColumn, hasReceiptColumn := columns["receipt"]
+
+	result := &Result{Receipts: []ImportedReceipt{}, Errors: []RowError{}}
+	var current *group
+	row := 1
+	for {
+		record, err2 := csvReader.Read()
+		if err2 == io.EOF {
+			break
+		}
+		row++
+		if err2 != nil {
+			result.Errors = append(result.Errors, RowError{Row: row, Message: err2.Error()})
+			continue
+		}
+		if len(record) != len(header) {
+			result.Errors = append(result.Errors, RowError{Row: row, Message: fmt.Sprintf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+		value := func(name string) string {
+			index, exists := columns[strings.ToLower(name)]
+			if !exists {
+				return ""
+			}
+			return record[index]
+		}
+
+		key := strings.Join([]string{value("retailer"), value("purchaseDate"), value("purchaseTime"), value("subtotal"), value("tax"), value("tip"), value("total"), value("currency"), value("timeZone")}, "\x00")
+		if hasReceiptColumn {
+			key = record[receiptColumn] + "\x00" + key
+		}
+		if current == nil || current.key != key {
+			if current != nil {
+				finish(current, options, result)
+			}
+			current = &group{
+				key: key,
+				receipt: receipt.Receipt{
+					Retailer:     value("retailer"),
+					PurchaseDate: value("purchaseDate"),
+					PurchaseTime: value("purchaseTime"),
+					Subtotal:     value("subtotal"),
+					Tax:          value("tax"),
+					Tip:          value("tip"),
+					Total:        value("total"),
+					Currency:     value("currency"),
+					TimeZone:     value("timeZone"),
+					Items:        []receipt.Item{},
+				},
+			}
+		}
+		current.rows = append(current.rows, row)
+		if amount, isDiscount := strings.CutPrefix(value("price"), "-"); isDiscount {
+			current.discountRows = append(current.discountRows, row)
+			current.receipt.Discounts = append(current.receipt.Discounts, receipt.Discount{
+				Description: value("shortDescription"),
+				Amount:      amount,
+			})
+			continue
+		}
+		current.itemRows = append(current.itemRows, row)
+		current.receipt.Items = append(current.receipt.Items, receipt.Item{
+			ShortDescription: value("shortDescription"),
+			Price:            value("price"),
+			Quantity:         value("quantity"),
+			UnitPrice:        value("unitPrice"),
+		})
+	}
+	if current != nil {
+		finish(current, options, result)
+	}
+	return result, nil
+}
+
+func finish(current *group, options receipt.ValidationOptions, result *Result) {
+	err := current.receipt.ValidateWith(options)
+	if err == nil {
+		result.Receipts = append(result.Receipts, ImportedReceipt{Receipt: current.receipt, Rows: current.rows})
+		return
+	}
+
+	var validationError *receipt.ValidationError
+	if !errors.As(err, &validationError) {
+		result.Errors = append(result.Errors, RowError{Row: current.rows[0], Message: err.Error()})
+		return
+	}
+	for _, problem := range validationError.Problems {
+		row := current.rows[0]
+		if problem.Item != nil {
+			row = current.itemRows[*problem.Item]
+		} else if problem.Discount != nil {
+			row = current.discountRows[*problem.Discount]
+		}
+		result.Errors = append(result.Errors, RowError{Row: row, Field: problem.Field, Message: problem.Message})
+	}
+}
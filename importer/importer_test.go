@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"receipt-processor/receipt"
+)
+
+const validCSV = `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25
+Walgreens,2022-01-02,08:13,2.65,Dasani,1.40
+Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25
+`
+
+func TestImportCSVGroupsRows(t *testing.T) {
+	result, err := ImportCSV(strings.NewReader(validCSV), receipt.ValidationOptions{})
+	if err != nil {
+		t.Fatalf("Should import the CSV ... %s", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Should have no row errors not %v", result.Errors)
+	}
+	if len(result.Receipts) != 2 {
+		t.Fatalf("Should have 2 receipts not %d", len(result.Receipts))
+	}
+	first := result.Receipts[0]
+	if first.Receipt.Retailer != "Walgreens" || len(first.Receipt.Items) != 2 || first.Receipt.Items[1].Price != "1.40" {
+		t.Errorf("Should group the Walgreens rows into one receipt not %v", first.Receipt)
+	}
+	if len(first.Rows) != 2 || first.Rows[0] != 2 || first.Rows[1] != 3 {
+		t.Errorf("Should record rows 2 and 3 not %v", first.Rows)
+	}
+	if result.Receipts[1].Rows[0] != 4 {
+		t.Errorf("Should start the Target receipt on row 4 not %v", result.Receipts[1].Rows)
+	}
+}
+
+func TestImportCSVReceiptColumnSeparatesReceipts(t *testing.T) {
+	csv := `receipt,retailer,purchaseDate,purchaseTime,total,shortDescription,price
+1,Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25
+2,Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25
+`
+	result, err := ImportCSV(strings.NewReader(csv), receipt.ValidationOptions{})
+	if err != nil || len(result.Receipts) != 2 {
+		t.Errorf("Should keep identical receipts apart by the receipt column ... %v %v", result, err)
+	}
+}
+
+func TestImportCSVReportsRowErrors(t *testing.T) {
+	csv := `Retailer,PurchaseDate,PurchaseTime,Total,ShortDescription,Price
+Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25
+Walgreens,2022-01-02,08:13,2.65,Dasani,1.4
+Target,2022-01-02,13:13
+Target,2022-13-02,13:13,1.25,Pepsi - 12-oz,1.25
+`
+	result, err := ImportCSV(strings.NewReader(csv), receipt.ValidationOptions{})
+	if err != nil {
+		t.Fatalf("Should import the CSV ... %s", err)
+	}
+	if len(result.Receipts) != 0 {
+		t.Errorf("Should not import invalid receipts not %v", result.Receipts)
+	}
+	expected := map[int]string{
+		3: "invalid format for price (1.4)",
+		4: "expected 6 fields, got 3",
+		5: "purchaseDate cannot be parsed (2022-13-02)",
+	}
+	for _, rowError := range result.Errors {
+		message, ok := expected[rowError.Row]
+		if !ok {
+			continue
+		}
+		if rowError.Message != message {
+			t.Errorf("Row %d should have error %q not %q", rowError.Row, message, rowError.Message)
+		}
+		delete(expected, rowError.Row)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Should have errors for rows %v ... %v", expected, result.Errors)
+	}
+}
+
+func TestImportCSVRejectsBadHeader(t *testing.T) {
+	_, err := ImportCSV(strings.NewReader("retailer,total\nTarget,1.25\n"), receipt.ValidationOptions{})
+	if err == nil || !strings.Contains(err.Error(), "purchaseDate") {
+		t.Errorf("Should name the missing columns ... %v", err)
+	}
+	_, err2 := ImportCSV(strings.NewReader(""), receipt.ValidationOptions{})
+	if err2 == nil {
+		t.Errorf("Should reject an empty CSV")
+	}
+}
+
+func TestImportCSVQuantitiesAndDiscounts(t *testing.T) {
+	csv := `retailer,purchaseDate,purchaseTime,total,shortDescription,price,quantity,unitPrice
+Target,2022-01-02,13:13,1.75,Gatorade,2.25,3,0.75
+Target,2022-01-02,13:13,1.75,Coupon,-0.50,,
+Walgreens,2022-01-02,08:13,1.00,Dasani,1.40,2,0.75
+Walgreens,2022-01-02,08:13,1.00,Coupon,-0.x0,,
+`
+	result, err := ImportCSV(strings.NewReader(csv), receipt.ValidationOptions{})
+	if err != nil {
+		t.Fatalf("Should import the CSV ... %s", err)
+	}
+	if len(result.Receipts) != 1 {
+		t.Fatalf("Should import the Target receipt not %+v", result.Receipts)
+	}
+	target := result.Receipts[0].Receipt
+	if target.Items[0].Quantity != "3" || len(target.Discounts) != 1 || target.Discounts[0].Amount != "0.50" {
+		t.Errorf("Should read quantities and discount rows not %+v", target)
+	}
+	rows := map[int]string{}
+	for _, rowError := range result.Errors {
+		rows[rowError.Row] += rowError.Message + ";"
+	}
+	if !strings.Contains(rows[4], "quantity (2) x unitPrice (0.75) = 1.50 != price (1.40)") || !strings.Contains(rows[5], "invalid format for amount (0.x0)") {
+		t.Errorf("Should report item and discount errors on their rows ... %v", rows)
+	}
+}
+
+func TestImportCSVSubtotalTaxAndTip(t *testing.T) {
+	csv := `retailer,purchaseDate,purchaseTime,subtotal,tax,tip,total,shortDescription,price
+Corner Cafe,2022-05-06,12:30,12.00,0.96,2.00,14.97,Sandwich,8.00
+Corner Cafe,2022-05-06,12:30,12.00,0.96,2.00,14.97,Soup,4.00
+`
+	result, err := ImportCSV(strings.NewReader(csv), receipt.ValidationOptions{})
+	if err != nil {
+		t.Fatalf("Should import the CSV ... %s", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Row != 2 || result.Errors[0].Field != "total" {
+		t.Errorf("Should report the total mismatch on row 2 not %v", result.Errors)
+	}
+
+	result2, _ := ImportCSV(strings.NewReader(csv), receipt.ValidationOptions{ToleranceCents: 1})
+	if len(result2.Errors) != 0 || len(result2.Receipts) != 1 || result2.Receipts[0].Receipt.Tip != "2.00" {
+		t.Errorf("Should import within the tolerance not %v %v", result2.Errors, result2.Receipts)
+	}
+}
@@ -1,383 +1,211 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"math"
+	"log/slog"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"strconv"
-	"strings"
-	"sync"
+	"syscall"
 	"time"
-	"unicode"
 
-	"github.com/google/uuid"
+	"receipt-processor/caps"
+	"receipt-processor/plugins"
+	"receipt-processor/receipt"
+	"receipt-processor/retailers"
+	"receipt-processor/server"
+	"receipt-processor/storage"
 )
 
-var rxDescription = regexp.MustCompile(`^[\w\s\-]+$`)
-var rxPrice = regexp.MustCompile(`^(\d+)\.(\d{2})$`)
-var rxRetailer = regexp.MustCompile(`^[\w\s\-&]+$`)
-var rxDate = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
-var rxTime = regexp.MustCompile(`^(\d{2}):(\d{2})$`)
-var twoPM, _ = time.Parse("15:04", "14:00")
-var fourPM, _ = time.Parse("15:04", "16:00")
-var dataStore = make(map[string]Receipt)
-var mu sync.RWMutex
+var version = "dev"
+var commit = ""
 
-type Item struct {
-	ShortDescription string `json:"shortDescription"`
-	Price            string `json:"price"`
-}
-
-type Receipt struct {
-	Retailer     string `json:"retailer"`
-	PurchaseDate string `json:"purchaseDate"`
-	PurchaseTime string `json:"purchaseTime"`
-	Items        []Item `json:"items"`
-	Total        string `json:"total"`
-}
-
-func (item *Item) Validate() error {
-	var errors []string
-
-	if strings.TrimSpace(item.ShortDescription) == "" {
-		errors = append(errors, "shortDescription cannot be empty")
-	} else if !rxDescription.MatchString(item.ShortDescription) {
-		errors = append(errors, fmt.Sprintf("invalid format for shortDescription (%s)", item.ShortDescription))
-	}
-
-	if strings.TrimSpace(item.Price) == "" {
-		errors = append(errors, "price cannot be empty")
-	} else if !rxPrice.MatchString(item.Price) {
-		errors = append(errors, fmt.Sprintf("invalid format for price (%s)", item.Price))
-	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf(strings.Join(errors, ", "))
-	}
-	return nil
-}
-
-func (item *Item) PointsForItem() (int, string) {
-	points := 0
-	trimmedDescription := strings.TrimSpace(item.ShortDescription)
-	if len(trimmedDescription)%3 == 0 {
-		itemPriceFloat, _ := strconv.ParseFloat(item.Price, 64)
-		points = int(math.Ceil(itemPriceFloat * 0.2))
+func drainDelay() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("DRAIN_SECONDS"))
+	if err != nil || seconds < 0 {
+		return 5 * time.Second
 	}
-	message := fmt.Sprintf("%d point(s) for item (%s | %s)", points, item.ShortDescription, item.Price)
-	return points, message
+	return time.Duration(seconds) * time.Second
 }
 
-func (item *Item) PointsForItemTitle() (int, string) {
-	points := 0
-	trimmedDescription := strings.TrimSpace(item.ShortDescription)
-	if trimmedDescription[0] == 'g' || trimmedDescription[0] == 'G' {
-		points = 10
+func totalTolerance() int {
+	cents, err := strconv.Atoi(os.Getenv("TOTAL_TOLERANCE_CENTS"))
+	if err != nil || cents < 0 {
+		return 0
 	}
-	message := fmt.Sprintf("%d point(s) for item title (%s | %s)", points, item.ShortDescription, item.Price)
-	return points, message
+	return cents
 }
 
-func (receipt *Receipt) Validate() error {
-	var errors []string
-
-	if strings.TrimSpace(receipt.Retailer) == "" {
-		errors = append(errors, "retailer cannot be empty")
-	} else if !rxRetailer.MatchString(receipt.Retailer) {
-		errors = append(errors, fmt.Sprintf("invalid format for retailer (%s)", receipt.Retailer))
-	}
-
-	if strings.TrimSpace(receipt.PurchaseDate) == "" {
-		errors = append(errors, "purchaseDate cannot be empty")
-	} else if !rxDate.MatchString(receipt.PurchaseDate) {
-		errors = append(errors, fmt.Sprintf("invalid format for purchaseDate (%s)", receipt.PurchaseDate))
-	} else {
-		_, err := time.Parse("2006-01-02", receipt.PurchaseDate)
+func validationOptions() (receipt.ValidationOptions, error) {
+	options := receipt.ValidationOptions{ToleranceCents: totalTolerance(), Punctuation: os.Getenv("ALLOWED_PUNCTUATION")}
+	if filename := os.Getenv("STORE_TIME_ZONES"); filename != "" {
+		zones, err := receipt.LoadTimeZones(filename)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("purchaseDate cannot be parsed (%s)", receipt.PurchaseDate))
+			return options, err
 		}
+		options.TimeZones = zones
 	}
-
-	if strings.TrimSpace(receipt.PurchaseTime) == "" {
-		errors = append(errors, "purchaseTime cannot be empty")
-	} else if !rxTime.MatchString(receipt.PurchaseTime) {
-		errors = append(errors, fmt.Sprintf("invalid format for purchaseTime (%s)", receipt.PurchaseTime))
-	} else {
-		_, err := time.Parse("15:04", receipt.PurchaseTime)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("purchaseTime cannot be parsed (%s)", receipt.PurchaseTime))
+	if zone := os.Getenv("DEFAULT_TIME_ZONE"); zone != "" {
+		if _, err2 := time.LoadLocation(zone); err2 != nil {
+			return options, fmt.Errorf("unknown time zone %q", zone)
 		}
+		options.TimeZones.Default = zone
 	}
+	return options, nil
+}
 
-	if strings.TrimSpace(receipt.Total) == "" {
-		errors = append(errors, "total cannot be empty")
-	} else if !rxPrice.MatchString(receipt.Total) {
-		errors = append(errors, fmt.Sprintf("invalid format for total (%s)", receipt.Total))
-	}
-
-	calculatedTotal := 0.0
-	for index, item := range receipt.Items {
-		itemPriceFloat, _ := strconv.ParseFloat(item.Price, 64)
-		calculatedTotal += itemPriceFloat
-		err := item.Validate()
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("item %d errors ... %s", index, err))
-		}
-	}
-
-	calculatedTotalString := fmt.Sprintf("%.2f", calculatedTotal)
-	if calculatedTotalString != receipt.Total {
-		errors = append(errors, fmt.Sprintf("sum of item prices (%s) != given total (%s)", calculatedTotalString, receipt.Total))
-	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf(strings.Join(errors, " | "))
+func loadRetailers() (*retailers.Registry, error) {
+	filename := os.Getenv("RETAILER_REGISTRY")
+	if filename == "" {
+		return nil, nil
 	}
-	return nil
+	return retailers.Load(filename)
 }
 
-func (receipt *Receipt) PointsForRetailerName() (int, string) {
-	points := 0
-	for _, char := range receipt.Retailer {
-		if unicode.IsLetter(char) || unicode.IsDigit(char) {
-			points += 1
+func selectRuleset() (*receipt.Ruleset, error) {
+	ruleset := receipt.StandardRuleset
+	if name := os.Getenv("RULESET"); name != "" {
+		var found bool
+		ruleset, found = receipt.Rulesets[name]
+		if !found {
+			return nil, fmt.Errorf("unknown ruleset %q", name)
 		}
 	}
-	message := fmt.Sprintf("%d points for retailer name (%s)", points, receipt.Retailer)
-	return points, message
-}
-
-func (receipt *Receipt) centsString() string {
-	return receipt.Total[len(receipt.Total)-2:]
-}
-
-func (receipt *Receipt) PointsForRoundDollarAmount() (int, string) {
-	points := 0
-	if receipt.centsString() == "00" {
-		points = 50
+	if amount := os.Getenv("RULE_AMOUNT"); amount != "" {
+		var err error
+		ruleset, err = ruleset.WithAmount(amount)
+		if err != nil {
+			return nil, err
+		}
 	}
-	message := fmt.Sprintf("%d points for round dollar amount (%s)", points, receipt.Total)
-	return points, message
-}
-
-func (receipt *Receipt) PointsForCentsMultiple25() (int, string) {
-	points := 0
-	cents, _ := strconv.Atoi(receipt.centsString())
-	if cents%25 == 0 {
-		points = 25
+	if filename := os.Getenv("EXPRESSION_RULES"); filename != "" {
+		expressions, err2 := receipt.LoadExpressionRules(filename)
+		if err2 != nil {
+			return nil, err2
+		}
+		var err3 error
+		ruleset, err3 = ruleset.WithExpressions(expressions)
+		if err3 != nil {
+			return nil, err3
+		}
 	}
-	message := fmt.Sprintf("%d points for being multiple of 0.25 (%s)", points, receipt.Total)
-	return points, message
-}
-
-func (receipt *Receipt) PointsForNumItems() (int, string) {
-	pairsOfItems := int(math.Floor(float64(len(receipt.Items)) / 2))
-	points := pairsOfItems * 5
-	message := fmt.Sprintf("%d points for number of items (%d)", points, len(receipt.Items))
-	return points, message
-}
-
-func (receipt *Receipt) PointsForPurchaseDate() (int, string) {
-	points := 0
-	match := rxDate.FindStringSubmatch(receipt.PurchaseDate)
-	dayInt, _ := strconv.Atoi(match[3])
-	if !(dayInt%2 == 0) {
-		points = 6
+	if filename := os.Getenv("PLUGIN_RULES"); filename != "" {
+		host, err4 := plugins.Load(filename)
+		if err4 != nil {
+			return nil, err4
+		}
+		var err5 error
+		ruleset, err5 = ruleset.WithRules(host.Rules()...)
+		if err5 != nil {
+			return nil, err5
+		}
 	}
-	message := fmt.Sprintf("%d points for purchase day being odd (%s)", points, receipt.PurchaseDate)
-	return points, message
-}
-
-func (receipt *Receipt) PointsForPurchaseTime() (int, string) {
-	points := 0
-	timeObj, _ := time.Parse("15:04", receipt.PurchaseTime)
-	if timeObj.After(twoPM) && timeObj.Before(fourPM) {
-		points = 10
+	if filename := os.Getenv("CURRENCY_CONFIG"); filename != "" {
+		config, err6 := receipt.LoadCurrencyConfig(filename)
+		if err6 != nil {
+			return nil, err6
+		}
+		ruleset = ruleset.WithCurrencies(config)
 	}
-	message := fmt.Sprintf("%d points for time of purchase between 2pm and 4pm (%s)", points, receipt.PurchaseTime)
-	return points, message
-}
-
-func (receipt *Receipt) GetTotalPointsAndBreakdown() (int, []string) {
-	var breakdown []string
-	totalPoints := 0
-
-	points, message := receipt.PointsForRetailerName()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	points, message = receipt.PointsForRoundDollarAmount()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	points, message = receipt.PointsForCentsMultiple25()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	points, message = receipt.PointsForNumItems()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	for _, item := range receipt.Items {
-		points, message = item.PointsForItem()
-		totalPoints += points
-		breakdown = append(breakdown, message)
-		points, message = item.PointsForItemTitle()
-		totalPoints += points
-		breakdown = append(breakdown, message)
+	if filename := os.Getenv("BONUS_RULES"); filename != "" {
+		bonuses, err7 := receipt.LoadBonuses(filename)
+		if err7 != nil {
+			return nil, err7
+		}
+		ruleset = ruleset.WithBonuses(bonuses)
 	}
-
-	points, message = receipt.PointsForPurchaseDate()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	points, message = receipt.PointsForPurchaseTime()
-	totalPoints += points
-	breakdown = append(breakdown, message)
-
-	return totalPoints, breakdown
-}
-
-func printDelimiter() {
-	fmt.Printf("\n\n" + strings.Repeat("-", 80) + "\n\n")
+	return ruleset, nil
 }
 
-func LoadJSON(filename string, v interface{}) error {
-	fp, err := os.Open(filename)
+// serverOptions configures the server from the environment. The point caps are
+// only set up when POINT_CAPS names a file, so without one receipts keep
+// following the current rules.
+func serverOptions(logger *slog.Logger) ([]server.Option, error) {
+	ruleset, err := selectRuleset()
 	if err != nil {
-		return fmt.Errorf("Error opening file: %w", err)
+		return nil, fmt.Errorf("could not select ruleset: %w", err)
 	}
-	defer fp.Close()
-
-	data, err2 := ioutil.ReadAll(fp)
-	if err != nil {
-		return fmt.Errorf("Error reading file: %w", err2)
+	validation, err2 := validationOptions()
+	if err2 != nil {
+		return nil, fmt.Errorf("could not load validation options: %w", err2)
 	}
-
-	err3 := json.Unmarshal(data, v)
+	registry, err3 := loadRetailers()
 	if err3 != nil {
-		return fmt.Errorf("Error unmarshaling JSON: %w", err3)
+		return nil, fmt.Errorf("could not load retailer registry: %w", err3)
+	}
+	options := []server.Option{
+		server.WithLogger(logger),
+		server.WithRuleset(ruleset),
+		server.WithBuildInfo(version, commit),
+		server.WithStrictJSON(os.Getenv("STRICT_JSON") != "false"),
+		server.WithAdminToken(os.Getenv("ADMIN_TOKEN")),
+		server.WithUserSecret(os.Getenv("USER_ID_SECRET")),
+		server.WithValidation(validation),
+		server.WithRetailers(registry),
+	}
+	if filename := os.Getenv("POINT_CAPS"); filename != "" {
+		limits, err4 := caps.Load(filename)
+		if err4 != nil {
+			return nil, fmt.Errorf("could not load point caps: %w", err4)
+		}
+		options = append(options, server.WithCaps(limits))
 	}
-
-	return nil
+	return options, nil
 }
 
-func handleError(writer http.ResponseWriter, statusCode int, message string) {
-	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(statusCode)
-	json.NewEncoder(writer).Encode(map[string]string{"error": message})
-	log.Println(fmt.Sprintf("(%d) ERROR %s", statusCode, message))
-}
-
-func handleReceiptPost(writer http.ResponseWriter, request *http.Request) {
-	log.Println(fmt.Sprintf("%s %s", request.Method, request.URL.Path))
-	if request.Method != http.MethodPost {
-		message := "Only POST is allowed"
-		handleError(writer, http.StatusMethodNotAllowed, message)
-		return
-	}
-	body, err := ioutil.ReadAll(request.Body)
-	if err != nil {
-		message := "Could not read request body"
-		handleError(writer, http.StatusBadRequest, message)
-		return
-	}
-	defer request.Body.Close()
-
-	var receipt Receipt
-	err2 := json.Unmarshal(body, &receipt)
-	if err2 != nil {
-		message := fmt.Sprintf("Error unmarshaling JSON: %s", err2.Error())
-		handleError(writer, http.StatusBadRequest, message)
-		return
-	}
-	err3 := receipt.Validate()
-	if err3 != nil {
-		message := fmt.Sprintf("Validation errors: %s", err3.Error())
-		handleError(writer, http.StatusBadRequest, message)
-		return
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
 	}
 
-	id := uuid.New().String()
-	mu.Lock()
-	dataStore[id] = receipt
-	mu.Unlock()
-
-	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusOK)
-	json.NewEncoder(writer).Encode(map[string]string{"id": id})
-	log.Println(fmt.Sprintf("(%d) OK %s", http.StatusOK, id))
-}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: server.LogLevel()}))
 
-func handleGetPoints(writer http.ResponseWriter, request *http.Request) {
-	log.Println(fmt.Sprintf("%s %s", request.Method, request.URL.Path))
-	if request.Method != http.MethodGet {
-		message := "Only GET is allowed"
-		handleError(writer, http.StatusMethodNotAllowed, message)
-		return
-	}
-	parts := strings.Split(request.URL.Path, "/")
-	id := parts[2]
-	mu.RLock()
-	receipt, exists := dataStore[id]
-	mu.RUnlock()
-	if !exists {
-		message := fmt.Sprintf("receipt %s not found", id)
-		handleError(writer, http.StatusNotFound, message)
-		return
+	shutdownTracing, err := server.SetupTracing(context.Background())
+	if err != nil {
+		logger.Error("could not set up tracing", "error", err)
+		os.Exit(1)
 	}
-	points, _ := receipt.GetTotalPointsAndBreakdown()
-	writer.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(writer).Encode(map[string]int{"points": points})
-	log.Println(fmt.Sprintf("(%d) OK points for %s", http.StatusOK, id))
-}
 
-func handleGetBreakdown(writer http.ResponseWriter, request *http.Request) {
-	log.Println(fmt.Sprintf("%s %s", request.Method, request.URL.Path))
-	if request.Method != http.MethodGet {
-		message := "Only GET is allowed"
-		handleError(writer, http.StatusMethodNotAllowed, message)
-		return
-	}
-	parts := strings.Split(request.URL.Path, "/")
-	id := parts[2]
-	mu.RLock()
-	receipt, exists := dataStore[id]
-	mu.RUnlock()
-	if !exists {
-		message := fmt.Sprintf("receipt %s not found", id)
-		handleError(writer, http.StatusNotFound, message)
-		return
+	options, err2 := serverOptions(logger)
+	if err2 != nil {
+		logger.Error("could not configure server", "error", err2)
+		os.Exit(1)
 	}
-	points, breakdown := receipt.GetTotalPointsAndBreakdown()
-	breakdown = append(breakdown, fmt.Sprintf("%d points total", points))
-	writer.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(writer).Encode(map[string][]string{"breakdown": breakdown})
-	log.Println(fmt.Sprintf("(%d) OK breakdown for %s", http.StatusOK, id))
-}
-
-func main() {
-	fmt.Println("This is the receipt processor!")
 
-	// var receipt Receipt
-	// err := LoadJSON("example3.json", &receipt)
-	// if err != nil {
-	// 	fmt.Println("Error loading JSON:", err)
-	// }
-	// fmt.Printf("This is the receipt data that was loaded: %v\n", receipt)
-	// errReceipt := receipt.Validate()
-	// fmt.Printf("Validation errors for receipt: %v\n", errReceipt)
-	// totalPoints, breakdown := receipt.GetTotalPointsAndBreakdown()
-	// fmt.Printf("\n%d total points\n\nbreakdown:\n%s\n", totalPoints, strings.Join(breakdown, "\n"))
-
-	http.HandleFunc("/receipts/process", handleReceiptPost)
-	http.HandleFunc("/receipts/{id}/points", handleGetPoints)
-	http.HandleFunc("/receipts/{id}/breakdown", handleGetBreakdown)
-	log.Println("Starting server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	receiptServer := server.New(storage.NewMemoryStore(), options...)
+	httpServer := &http.Server{Addr: ":8080", Handler: receiptServer.Handler()}
+	stopped := make(chan error, 1)
+	go func() {
+		attrs := []any{"addr", httpServer.Addr}
+		for key, value := range receiptServer.BuildInfo() {
+			attrs = append(attrs, key, value)
+		}
+		logger.Info("starting server", attrs...)
+		stopped <- httpServer.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err3 := <-stopped:
+		logger.Error("server stopped", "error", err3)
+		shutdownTracing(context.Background())
+		os.Exit(1)
+	case received := <-signals:
+		drainDelay := drainDelay()
+		logger.Info("draining before shutdown", "signal", received.String(), "delay", drainDelay.String())
+		receiptServer.SetDraining(true)
+		time.Sleep(drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err4 := httpServer.Shutdown(ctx)
+	if err4 != nil {
+		logger.Error("server shutdown", "error", err4)
+	}
+	shutdownTracing(ctx)
+	logger.Info("server stopped")
 }
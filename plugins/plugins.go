@@ -0,0 +1,216 @@
+// Package plugins runs scoring rules compiled to WebAssembly in a sandbox.
+//
+// A plugin is a WASI command. It reads the receipt as JSON on stdin and writes
+// {"points": 5, "message": "5 points for ..."} to stdout before exiting.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"receipt-processor/receipt"
+)
+
+const DefaultTimeout = 100 * time.Millisecond
+const DefaultMemoryMiB = 64
+const maxOutput = 64 * 1024
+
+var rxPluginName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type Config struct {
+	Timeout   string   `json:"timeout,omitempty"`
+	MemoryMiB int      `json:"memoryMiB,omitempty"`
+	Plugins   []Plugin `json:"plugins"`
+}
+
+type result struct {
+	Points  *int   `json:"points"`
+	Message string `json:"message"`
+}
+
+type Host struct {
+	runtime wazero.Runtime
+	timeout time.Duration
+	modules map[string]wazero.CompiledModule
+	names   []string
+}
+
+func Load(filename string) (*Host, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading plugin config: %w", err)
+	}
+	var config Config
+	err2 := json.Unmarshal(data, &config)
+	if err2 != nil {
+		return nil, fmt.Errorf("Error unmarshaling plugin config: %w", err2)
+	}
+	for index := range config.Plugins {
+		if path := config.Plugins[index].Path; path != "" && !filepath.IsAbs(path) {
+			config.Plugins[index].Path = filepath.Join(filepath.Dir(filename), path)
+		}
+	}
+	return New(context.Background(), config)
+}
+
+// New compiles every plugin up front, so a module that is not a WASI command
+// or asks for more memory than the limit stops the server from starting.
+func New(ctx context.Context, config Config) (*Host, error) {
+	timeout := DefaultTimeout
+	if config.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid plugin timeout %q", config.Timeout)
+		}
+	}
+	memoryMiB := DefaultMemoryMiB
+	if config.MemoryMiB > 0 {
+		memoryMiB = config.MemoryMiB
+	}
+
+	runtimeConfig := wazero.NewRuntimeConfig().WithMemoryLimitPages(uint32(memoryMiB * 16)).WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+	host := &Host{runtime: runtime, timeout: timeout, modules: make(map[string]wazero.CompiledModule)}
+	for _, plugin := range config.Plugins {
+		err2 := host.compile(ctx, plugin)
+		if err2 != nil {
+			runtime.Close(ctx)
+			return nil, err2
+		}
+	}
+	return host, nil
+}
+
+func (host *Host) compile(ctx context.Context, plugin Plugin) error {
+	if !rxPluginName.MatchString(plugin.Name) || host.modules[plugin.Name] != nil {
+		return fmt.Errorf("plugin %s needs a unique lower case name", plugin.Path)
+	}
+	binary, err := os.ReadFile(plugin.Path)
+	if err != nil {
+		return fmt.Errorf("Error reading plugin %s: %w", plugin.Name, err)
+	}
+	compiled, err2 := host.runtime.CompileModule(ctx, binary)
+	if err2 != nil {
+		return fmt.Errorf("plugin %s: %w", plugin.Name, err2)
+	}
+	if _, exists := compiled.ExportedFunctions()["_start"]; !exists {
+		return fmt.Errorf("plugin %s is not a WASI command, it does not export _start", plugin.Name)
+	}
+	host.modules[plugin.Name] = compiled
+	host.names = append(host.names, plugin.Name)
+	return nil
+}
+
+func (host *Host) Close(ctx context.Context) error {
+	return host.runtime.Close(ctx)
+}
+
+// Rules gives a rule per plugin, in the order of the config. Each run is
+// bounded by the scoring request's context as well as the timeout.
+func (host *Host) Rules() []receipt.Rule {
+	var rules []receipt.Rule
+	for _, name := range host.names {
+		rules = append(rules, receipt.Rule{Name: name, ReceiptContext: func(ctx context.Context, scored *receipt.Receipt) (int, string) {
+			points, message, err := host.Run(ctx, name, scored)
+			if err != nil {
+				return 0, fmt.Sprintf("0 points for %s, it failed: %s", name, err)
+			}
+			return points, message
+		}})
+	}
+	return rules
+}
+
+// Run scores the receipt in a fresh instance of the plugin. The instance gets
+// no files, environment, real clock or randomness, so a plugin scores a
+// receipt the same way every time.
+func (host *Host) Run(ctx context.Context, name string, scored *receipt.Receipt) (int, string, error) {
+	compiled, exists := host.modules[name]
+	if !exists {
+		return 0, "", fmt.Errorf("unknown plugin %s", name)
+	}
+	input, err := json.Marshal(scored)
+	if err != nil {
+		return 0, "", err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, host.timeout)
+	defer cancel()
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: 1024}
+	moduleConfig := wazero.NewModuleConfig().WithName("").WithArgs(name).WithStdin(bytes.NewReader(input)).WithStdout(stdout).WithStderr(stderr)
+	module, err2 := host.runtime.InstantiateModule(runCtx, compiled, moduleConfig)
+	if module != nil {
+		module.Close(context.Background())
+	}
+	var exitError *sys.ExitError
+	if errors.As(err2, &exitError) && exitError.ExitCode() == 0 {
+		err2 = nil
+	}
+	if err2 != nil {
+		if ctx.Err() != nil {
+			return 0, "", fmt.Errorf("stopped: %w", ctx.Err())
+		}
+		if runCtx.Err() != nil {
+			return 0, "", fmt.Errorf("took longer than %s", host.timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return 0, "", fmt.Errorf("%s: %s", firstLine(err2.Error()), firstLine(message))
+		}
+		return 0, "", errors.New(firstLine(err2.Error()))
+	}
+
+	var output result
+	err3 := json.Unmarshal(stdout.Bytes(), &output)
+	if err3 != nil || output.Points == nil {
+		return 0, "", fmt.Errorf("wrote %q instead of a JSON object with points", truncate(stdout.String(), 100))
+	}
+	if output.Message == "" {
+		output.Message = fmt.Sprintf("%d points for %s", *output.Points, name)
+	}
+	return *output.Points, output.Message, nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (buffer *limitedBuffer) Write(data []byte) (int, error) {
+	if buffer.Len()+len(data) > buffer.limit {
+		return 0, io.ErrShortWrite
+	}
+	return buffer.Buffer.Write(data)
+}
+
+func firstLine(value string) string {
+	line, _, _ := strings.Cut(value, "\n")
+	return line
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length] + "..."
+}
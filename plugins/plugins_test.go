@@ -0,0 +1,124 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"receipt-processor/receipt"
+)
+
+var example = receipt.Receipt{
+	Retailer:     "Target",
+	PurchaseDate: "2022-01-01",
+	PurchaseTime: "13:01",
+	Items: []receipt.Item{
+		{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+		{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+		{ShortDescription: "Klarbrunn 12-PK 12 FL OZ", Price: "12.00"},
+	},
+	Total: "30.74",
+}
+
+// testPlugins configures the plugins in testdata. Each .wasm file is assembled
+// from the .wat file next to it, with wat2wasm for instance.
+func testPlugins(names ...string) Config {
+	config := Config{Timeout: "1s", MemoryMiB: 64}
+	for _, name := range names {
+		config.Plugins = append(config.Plugins, Plugin{Name: name, Path: filepath.Join("testdata", name+".wasm")})
+	}
+	return config
+}
+
+func TestPluginRules(t *testing.T) {
+	config := testPlugins("items", "loop", "greedy", "silent")
+	host, err := New(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Should compile the plugins ... %s", err)
+	}
+	defer host.Close(context.Background())
+
+	points, message, err2 := host.Run(context.Background(), "items", &example)
+	if err2 != nil || points != 4 || message != "4 points for pricey items" {
+		t.Errorf("Should score the receipt in the plugin not %d %q ... %v", points, message, err2)
+	}
+
+	expected := map[string]string{"loop": "took longer than 1s", "greedy": "out of memory", "silent": "instead of a JSON object"}
+	for name, reason := range expected {
+		_, _, err3 := host.Run(context.Background(), name, &example)
+		if err3 == nil || !strings.Contains(err3.Error(), reason) {
+			t.Errorf("Should stop the %s plugin with %q ... %v", name, reason, err3)
+		}
+	}
+
+	ruleset, err4 := receipt.StandardRuleset.WithRules(host.Rules()...)
+	if err4 != nil {
+		t.Fatalf("Should add the plugin rules ... %s", err4)
+	}
+	_, breakdown := receipt.SummarizeRules(ruleset.Evaluate(context.Background(), &example))
+	joined := strings.Join(breakdown, "\n")
+	if !strings.Contains(joined, "4 points for pricey items") || !strings.Contains(joined, "0 points for loop, it failed: took longer than 1s") {
+		t.Errorf("Should slot the plugins into the breakdown ... %s", joined)
+	}
+}
+
+func TestPluginRulesFollowTheScoringContext(t *testing.T) {
+	host, err := New(context.Background(), testPlugins("loop"))
+	if err != nil {
+		t.Fatalf("Should compile the plugins ... %s", err)
+	}
+	defer host.Close(context.Background())
+	ruleset, _ := receipt.StandardRuleset.WithRules(host.Rules()...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, breakdown := receipt.SummarizeRules(ruleset.Evaluate(ctx, &example))
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("Should stop the plugin with the scoring context not after %s", elapsed)
+	}
+	if joined := strings.Join(breakdown, "\n"); !strings.Contains(joined, "0 points for loop, it failed: stopped: context deadline exceeded") {
+		t.Errorf("Should say the scoring request stopped the plugin ... %s", joined)
+	}
+}
+
+func TestNewRejectsBadPlugins(t *testing.T) {
+	directory := t.TempDir()
+	notWasm := filepath.Join(directory, "text.wasm")
+	os.WriteFile(notWasm, []byte("not wasm"), 0o644)
+	// A module with one memory of 2000 pages (125MiB) and nothing else.
+	tooBig := filepath.Join(directory, "big.wasm")
+	os.WriteFile(tooBig, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x05, 0x04, 0x01, 0x00, 0xd0, 0x0f}, 0o644)
+
+	invalid := map[string]Config{
+		"not wasm":    {Plugins: []Plugin{{Name: "text", Path: notWasm}}},
+		"too much":    {MemoryMiB: 64, Plugins: []Plugin{{Name: "big", Path: tooBig}}},
+		"missing":     {Plugins: []Plugin{{Name: "missing", Path: filepath.Join(directory, "missing.wasm")}}},
+		"bad name":    {Plugins: []Plugin{{Name: "Big Plugin", Path: tooBig}}},
+		"bad timeout": {Timeout: "soon"},
+	}
+	for name, config := range invalid {
+		if _, err := New(context.Background(), config); err == nil {
+			t.Errorf("Should reject %s", name)
+		}
+	}
+}
+
+func TestLoadResolvesPaths(t *testing.T) {
+	directory := t.TempDir()
+	binary, _ := os.ReadFile(filepath.Join("testdata", "items.wasm"))
+	os.WriteFile(filepath.Join(directory, "items.wasm"), binary, 0o644)
+	filename := filepath.Join(directory, "plugins.json")
+	os.WriteFile(filename, []byte(`{"timeout": "2s", "plugins": [{"name": "items", "path": "items.wasm"}]}`), 0o644)
+	host, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Should load plugins relative to the config ... %s", err)
+	}
+	defer host.Close(context.Background())
+	if rules := host.Rules(); len(rules) != 1 || rules[0].Name != "items" {
+		t.Errorf("Should give one rule per plugin not %+v", rules)
+	}
+}
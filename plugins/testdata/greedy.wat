@@ -0,0 +1,31 @@
+;; greedy asks for 2000 more pages (125MiB) of memory, more than plugins get,
+;; and fails the way a Go command does when it runs out of memory.
+(module
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 16) "fatal error: out of memory\n")
+  (func $_start (export "_start")
+    i32.const 2000
+    memory.grow
+    i32.const -1
+    i32.ne
+    if
+      return
+    end
+    i32.const 0
+    i32.const 16
+    i32.store
+    i32.const 4
+    i32.const 27
+    i32.store
+    i32.const 2
+    i32.const 0
+    i32.const 1
+    i32.const 8
+    call $fd_write
+    drop
+    i32.const 2
+    call $proc_exit
+  )
+)
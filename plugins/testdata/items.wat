@@ -0,0 +1,249 @@
+;; items gives 2 points per item over 10.00 on a receipt. It reads the receipt
+;; from stdin into memory at 1024 and looks for "price":" keys, whose values
+;; always have two decimals, then writes the result from 61440.
+(module
+  (import "wasi_snapshot_preview1" "fd_read" (func $fd_read (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 64) "\"price\":\"")
+  (data (i32.const 80) "{\"points\": ")
+  (data (i32.const 96) ", \"message\": \"")
+  (data (i32.const 112) " points for pricey items\"}\n")
+
+  ;; matches gives 1 if the 9 bytes at $at are the "price":" key.
+  (func $matches (param $at i32) (result i32) (local $index i32)
+    block
+      loop
+        local.get $index
+        i32.const 9
+        i32.eq
+        br_if 1
+        local.get $at
+        local.get $index
+        i32.add
+        i32.load8_u
+        local.get $index
+        i32.load8_u offset=64
+        i32.ne
+        if
+          i32.const 0
+          return
+        end
+        local.get $index
+        i32.const 1
+        i32.add
+        local.set $index
+        br 0
+      end
+    end
+    i32.const 1
+  )
+
+  ;; copy copies $length bytes from $from to $to and gives the end of the copy.
+  (func $copy (param $to i32) (param $from i32) (param $length i32) (result i32)
+    block
+      loop
+        local.get $length
+        i32.eqz
+        br_if 1
+        local.get $to
+        local.get $from
+        i32.load8_u
+        i32.store8
+        local.get $to
+        i32.const 1
+        i32.add
+        local.set $to
+        local.get $from
+        i32.const 1
+        i32.add
+        local.set $from
+        local.get $length
+        i32.const 1
+        i32.sub
+        local.set $length
+        br 0
+      end
+    end
+    local.get $to
+  )
+
+  ;; digits writes $number in decimal at $to and gives the end of the digits.
+  (func $digits (param $to i32) (param $number i32) (result i32) (local $divisor i32)
+    i32.const 1
+    local.set $divisor
+    block
+      loop
+        local.get $number
+        local.get $divisor
+        i32.div_u
+        i32.const 10
+        i32.lt_u
+        br_if 1
+        local.get $divisor
+        i32.const 10
+        i32.mul
+        local.set $divisor
+        br 0
+      end
+    end
+    block
+      loop
+        local.get $to
+        local.get $number
+        local.get $divisor
+        i32.div_u
+        i32.const 10
+        i32.rem_u
+        i32.const 48
+        i32.add
+        i32.store8
+        local.get $to
+        i32.const 1
+        i32.add
+        local.set $to
+        local.get $divisor
+        i32.const 10
+        i32.div_u
+        local.tee $divisor
+        i32.eqz
+        br_if 1
+        br 0
+      end
+    end
+    local.get $to
+  )
+
+  (func $_start (export "_start") (local $end i32) (local $at i32) (local $cents i32) (local $byte i32) (local $points i32)
+    i32.const 1024
+    local.set $end
+    block
+      loop
+        i32.const 0
+        local.get $end
+        i32.store
+        i32.const 4
+        i32.const 61440
+        local.get $end
+        i32.sub
+        i32.store
+        i32.const 0
+        i32.const 0
+        i32.const 1
+        i32.const 8
+        call $fd_read
+        br_if 1
+        i32.const 8
+        i32.load
+        i32.eqz
+        br_if 1
+        local.get $end
+        i32.const 8
+        i32.load
+        i32.add
+        local.set $end
+        br 0
+      end
+    end
+
+    i32.const 1024
+    local.set $at
+    block
+      loop
+        local.get $at
+        i32.const 9
+        i32.add
+        local.get $end
+        i32.gt_u
+        br_if 1
+        local.get $at
+        call $matches
+        if
+          local.get $at
+          i32.const 9
+          i32.add
+          local.set $at
+          i32.const 0
+          local.set $cents
+          block
+            loop
+              local.get $at
+              i32.load8_u
+              local.set $byte
+              local.get $at
+              i32.const 1
+              i32.add
+              local.set $at
+              local.get $byte
+              i32.const 46
+              i32.eq
+              br_if 0
+              local.get $byte
+              i32.const 48
+              i32.lt_u
+              local.get $byte
+              i32.const 57
+              i32.gt_u
+              i32.or
+              br_if 1
+              local.get $cents
+              i32.const 10
+              i32.mul
+              local.get $byte
+              i32.const 48
+              i32.sub
+              i32.add
+              local.set $cents
+              br 0
+            end
+          end
+          local.get $cents
+          i32.const 1000
+          i32.gt_u
+          if
+            local.get $points
+            i32.const 2
+            i32.add
+            local.set $points
+          end
+        else
+          local.get $at
+          i32.const 1
+          i32.add
+          local.set $at
+        end
+        br 0
+      end
+    end
+
+    i32.const 61440
+    i32.const 80
+    i32.const 11
+    call $copy
+    local.get $points
+    call $digits
+    i32.const 96
+    i32.const 14
+    call $copy
+    local.get $points
+    call $digits
+    i32.const 112
+    i32.const 27
+    call $copy
+    local.set $end
+    i32.const 16
+    i32.const 61440
+    i32.store
+    i32.const 20
+    local.get $end
+    i32.const 61440
+    i32.sub
+    i32.store
+    i32.const 1
+    i32.const 16
+    i32.const 1
+    i32.const 24
+    call $fd_write
+    drop
+  )
+)
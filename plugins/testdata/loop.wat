@@ -0,0 +1,8 @@
+;; loop never finishes.
+(module
+  (func $_start (export "_start")
+    loop
+      br 0
+    end
+  )
+)
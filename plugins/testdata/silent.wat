@@ -0,0 +1,5 @@
+;; silent exits without a result.
+(module
+  (func $_start (export "_start")
+  )
+)
@@ -0,0 +1,442 @@
+// Package printout parses the plain text of point-of-sale receipt printouts
+// into receipts, with a confidence score for each parsed field.
+package printout
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"receipt-processor/receipt"
+)
+
+var rxISODate = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+var rxNumericDate = regexp.MustCompile(`\b(\d{1,2})([/.\-])(\d{1,2})[/.\-](\d{4}|\d{2})\b`)
+var rxNamedDate = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2}),?\s+(\d{4})\b`)
+var rxTimeOfDay = regexp.MustCompile(`(?i)\b(\d{1,2}):(\d{2})(?::\d{2})?\s*([ap]\.?m\.?)?(?:\s|$)`)
+var rxAmount = regexp.MustCompile(`(?:^|\s)(-?)\$?\s?(\d{1,3}(?:,\d{3})+|\d+)\.(\d{2})(?:\s+[A-Z]{1,2})?\s*$`)
+var rxTotalLine = regexp.MustCompile(`(?i)^\s*(grand\s+total|total\s+due|total|amount\s+due|balance\s+due)\b`)
+var rxSkipLine = regexp.MustCompile(`(?i)\b(sub\s*-?total|tax|change|cash|visa|mastercard|amex|debit|credit|card|tender|payment|savings|you saved|items? sold|tip)\b`)
+var rxSubtotalLine = regexp.MustCompile(`(?i)^\s*sub\s*-?total\b`)
+var rxTaxLine = regexp.MustCompile(`(?i)^\s*(?:sales\s+)?tax\b`)
+var rxTipLine = regexp.MustCompile(`(?i)^\s*(?:tip|gratuity)\b`)
+var rxQuantityLine = regexp.MustCompile(`^\s*(\d+(?:\.\d{1,3})?)\s*(?:[@xX]|lb\s*@)\s*\$?(\d+\.\d{2,3})`)
+var rxInlineQuantity = regexp.MustCompile(`^(\d+)\s*[xX]\s+(.*?)(?:\s+@\s*\$?(\d+\.\d{2,3}))?$`)
+var rxNotRetailer = regexp.MustCompile(`(?i)(^\d|www\.|\.com\b|https?:|\btel\b|\bphone\b|\(\d{3}\)|\d{3}-\d{4}|\breceipt\b|\bstore\s*#|\bcashier\b|\bregister\b)`)
+var rxDescriptionChars = regexp.MustCompile(`[^\p{L}\p{M}\p{N}\s\-_]+`)
+var rxRetailerChars = regexp.MustCompile(`[^\p{L}\p{M}\p{N}\s\-&_]+`)
+var apostrophes = strings.NewReplacer("'", "", "’", "")
+
+var months = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+
+type ItemConfidence struct {
+	ShortDescription float64 `json:"shortDescription"`
+	Price            float64 `json:"price"`
+}
+
+type DiscountConfidence struct {
+	Description float64 `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+type Confidence struct {
+	Retailer     float64              `json:"retailer"`
+	PurchaseDate float64              `json:"purchaseDate"`
+	PurchaseTime float64              `json:"purchaseTime"`
+	Subtotal     float64              `json:"subtotal,omitempty"`
+	Tax          float64              `json:"tax,omitempty"`
+	Tip          float64              `json:"tip,omitempty"`
+	Total        float64              `json:"total"`
+	Items        []ItemConfidence     `json:"items"`
+	Discounts    []DiscountConfidence `json:"discounts,omitempty"`
+}
+
+func (confidence Confidence) Min() float64 {
+	lowest := math.Min(math.Min(confidence.Retailer, confidence.PurchaseDate), math.Min(confidence.PurchaseTime, confidence.Total))
+	if len(confidence.Items) == 0 {
+		return 0
+	}
+	for _, score := range []float64{confidence.Subtotal, confidence.Tax, confidence.Tip} {
+		if score > 0 {
+			lowest = math.Min(lowest, score)
+		}
+	}
+	for _, item := range confidence.Items {
+		lowest = math.Min(lowest, math.Min(item.ShortDescription, item.Price))
+	}
+	for _, discount := range confidence.Discounts {
+		lowest = math.Min(lowest, math.Min(discount.Description, discount.Amount))
+	}
+	return lowest
+}
+
+func (confidence *Confidence) round() {
+	for _, score := range []*float64{&confidence.Retailer, &confidence.PurchaseDate, &confidence.PurchaseTime, &confidence.Subtotal, &confidence.Tax, &confidence.Tip, &confidence.Total} {
+		*score = math.Round(*score*100) / 100
+	}
+	for index := range confidence.Items {
+		confidence.Items[index].ShortDescription = math.Round(confidence.Items[index].ShortDescription*100) / 100
+		confidence.Items[index].Price = math.Round(confidence.Items[index].Price*100) / 100
+	}
+	for index := range confidence.Discounts {
+		confidence.Discounts[index].Description = math.Round(confidence.Discounts[index].Description*100) / 100
+		confidence.Discounts[index].Amount = math.Round(confidence.Discounts[index].Amount*100) / 100
+	}
+}
+
+type Result struct {
+	Receipt    receipt.Receipt `json:"receipt"`
+	Confidence Confidence      `json:"confidence"`
+	Warnings   []string        `json:"warnings"`
+}
+
+func Parse(text string) Result {
+	lines := strings.Split(strings.ReplaceAll(norm.NFC.String(text), "\r\n", "\n"), "\n")
+	result := Result{
+		Receipt:    receipt.Receipt{Items: []receipt.Item{}},
+		Confidence: Confidence{Items: []ItemConfidence{}},
+		Warnings:   []string{},
+	}
+
+	firstAmount := len(lines)
+	totalLine := -1
+	amountLines := []struct {
+		pattern    *regexp.Regexp
+		amount     *string
+		confidence *float64
+	}{
+		{rxSubtotalLine, &result.Receipt.Subtotal, &result.Confidence.Subtotal},
+		{rxTaxLine, &result.Receipt.Tax, &result.Confidence.Tax},
+		{rxTipLine, &result.Receipt.Tip, &result.Confidence.Tip},
+	}
+	for index, line := range lines {
+		for _, amountLine := range amountLines {
+			if *amountLine.amount == "" && amountLine.pattern.MatchString(line) && rxAmount.MatchString(line) {
+				*amountLine.amount, *amountLine.confidence = parseAmount(line)
+			}
+		}
+		if rxAmount.MatchString(line) && firstAmount == len(lines) {
+			firstAmount = index
+		}
+		if totalLine == -1 && rxTotalLine.MatchString(line) && !rxSkipLine.MatchString(line) && rxAmount.MatchString(line) {
+			totalLine = index
+		}
+	}
+
+	parseRetailer(lines[:firstAmount], &result)
+	parseDateAndTime(lines, &result)
+
+	itemsEnd := len(lines)
+	if totalLine >= 0 {
+		itemsEnd = totalLine
+	}
+	pending := &pendingQuantity{}
+	for index := firstAmount; index < itemsEnd; index++ {
+		parseItem(lines[index], pending, &result)
+	}
+
+	itemSum := 0
+	for _, item := range result.Receipt.Items {
+		itemSum += cents(item.Price)
+	}
+	for _, discount := range result.Receipt.Discounts {
+		itemSum -= cents(discount.Amount)
+	}
+	expected := itemSum
+	description := "the sum of the items less discounts"
+	if result.Receipt.Subtotal != "" && cents(result.Receipt.Subtotal) != itemSum {
+		result.Confidence.Subtotal *= 0.5
+		result.Warnings = append(result.Warnings, fmt.Sprintf("subtotal %s does not match the sum of the items less discounts (%s)", result.Receipt.Subtotal, formatCents(itemSum)))
+	}
+	if result.Receipt.Subtotal != "" || result.Receipt.Tax != "" || result.Receipt.Tip != "" {
+		if result.Receipt.Subtotal != "" {
+			expected = cents(result.Receipt.Subtotal)
+		}
+		expected += cents(result.Receipt.Tax) + cents(result.Receipt.Tip)
+		description = "the subtotal plus tax and tip"
+	}
+	if totalLine >= 0 {
+		amount, amountConfidence := parseAmount(lines[totalLine])
+		result.Receipt.Total = amount
+		result.Confidence.Total = amountConfidence
+		label := strings.ToLower(rxTotalLine.FindStringSubmatch(lines[totalLine])[1])
+		if label != "total" && label != "grand total" {
+			result.Confidence.Total *= 0.8
+		}
+		if cents(amount) != expected {
+			result.Confidence.Total *= 0.5
+			result.Warnings = append(result.Warnings, fmt.Sprintf("total %s does not match %s (%s)", amount, description, formatCents(expected)))
+		}
+	} else if len(result.Receipt.Items) > 0 {
+		result.Receipt.Total = formatCents(expected)
+		result.Confidence.Total = 0.3
+		result.Warnings = append(result.Warnings, fmt.Sprintf("no TOTAL line found, using %s", description))
+	} else {
+		result.Warnings = append(result.Warnings, "no TOTAL line found")
+	}
+	if len(result.Receipt.Items) == 0 {
+		result.Warnings = append(result.Warnings, "no item lines found")
+	}
+	result.Confidence.round()
+	return result
+}
+
+func parseRetailer(header []string, result *Result) {
+	skipped := 0
+	for _, line := range header {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if rxNotRetailer.MatchString(trimmed) || rxISODate.MatchString(trimmed) || rxNumericDate.MatchString(trimmed) || rxTimeOfDay.MatchString(trimmed) {
+			skipped++
+			continue
+		}
+		letters := 0
+		for _, r := range trimmed {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters < 2 {
+			skipped++
+			continue
+		}
+
+		confidence := 0.9
+		if skipped > 0 {
+			confidence = 0.7
+		}
+		lower := strings.ToLower(trimmed)
+		for _, prefix := range []string{"welcome to ", "thank you for shopping at ", "thanks for shopping at "} {
+			if strings.HasPrefix(lower, prefix) {
+				trimmed = strings.TrimSpace(trimmed[len(prefix):])
+				confidence = 0.8
+			}
+		}
+		cleaned := clean(rxRetailerChars, trimmed)
+		if cleaned != trimmed {
+			confidence -= 0.2
+		}
+		if cleaned == "" {
+			continue
+		}
+		result.Receipt.Retailer = cleaned
+		result.Confidence.Retailer = confidence
+		return
+	}
+	result.Warnings = append(result.Warnings, "no retailer header found")
+}
+
+func parseDateAndTime(lines []string, result *Result) {
+	for _, line := range lines {
+		if result.Receipt.PurchaseDate == "" {
+			date, confidence := parseDate(line)
+			if date != "" {
+				result.Receipt.PurchaseDate = date
+				result.Confidence.PurchaseDate = confidence
+			}
+		}
+		if result.Receipt.PurchaseTime == "" {
+			withoutDates := rxISODate.ReplaceAllString(rxNumericDate.ReplaceAllString(line, ""), "")
+			clock, confidence := parseTime(withoutDates)
+			if clock != "" {
+				result.Receipt.PurchaseTime = clock
+				result.Confidence.PurchaseTime = confidence
+			}
+		}
+	}
+	if result.Receipt.PurchaseDate == "" {
+		result.Warnings = append(result.Warnings, "no purchase date found")
+	}
+	if result.Receipt.PurchaseTime == "" {
+		result.Warnings = append(result.Warnings, "no purchase time found")
+	}
+}
+
+func parseDate(line string) (string, float64) {
+	if match := rxISODate.FindStringSubmatch(line); match != nil {
+		return formatDate(atoi(match[1]), atoi(match[2]), atoi(match[3]), 1.0)
+	}
+	if match := rxNamedDate.FindStringSubmatch(line); match != nil {
+		return formatDate(atoi(match[3]), months[strings.ToLower(match[1])[:3]], atoi(match[2]), 0.95)
+	}
+	if match := rxNumericDate.FindStringSubmatch(line); match != nil {
+		first, second, year := atoi(match[1]), atoi(match[3]), atoi(match[4])
+		confidence := 0.9
+		if len(match[4]) == 2 {
+			year += 2000
+			confidence = 0.8
+		}
+		switch {
+		case first > 12:
+			return formatDate(year, second, first, confidence)
+		case second > 12:
+			return formatDate(year, first, second, confidence)
+		case match[2] == ".":
+			return formatDate(year, second, first, confidence*0.8)
+		}
+		return formatDate(year, first, second, confidence*0.8)
+	}
+	return "", 0
+}
+
+func formatDate(year int, month int, day int, confidence float64) (string, float64) {
+	date := fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	_, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return "", 0
+	}
+	return date, confidence
+}
+
+func parseTime(line string) (string, float64) {
+	match := rxTimeOfDay.FindStringSubmatch(line)
+	if match == nil {
+		return "", 0
+	}
+	hour, minute := atoi(match[1]), atoi(match[2])
+	confidence := 1.0
+	if match[3] != "" {
+		if hour < 1 || hour > 12 {
+			return "", 0
+		}
+		pm := strings.HasPrefix(strings.ToLower(match[3]), "p")
+		if pm && hour != 12 {
+			hour += 12
+		} else if !pm && hour == 12 {
+			hour = 0
+		}
+		confidence = 0.95
+	}
+	if hour > 23 || minute > 59 {
+		return "", 0
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), confidence
+}
+
+type pendingQuantity struct {
+	quantity  string
+	unitPrice string
+}
+
+func (pending *pendingQuantity) applyTo(item *receipt.Item) bool {
+	if pending.quantity == "" || item.Quantity != "" {
+		return false
+	}
+	candidate := *item
+	candidate.Quantity = pending.quantity
+	candidate.UnitPrice = pending.unitPrice
+	if candidate.Validate() != nil {
+		return false
+	}
+	*item = candidate
+	pending.quantity = ""
+	return true
+}
+
+func parseItem(line string, pending *pendingQuantity, result *Result) {
+	if strings.TrimSpace(line) == "" || rxSkipLine.MatchString(line) || rxTotalLine.MatchString(line) {
+		return
+	}
+	if match := rxQuantityLine.FindStringSubmatch(line); match != nil {
+		pending.quantity, pending.unitPrice = match[1], match[2]
+		if items := result.Receipt.Items; len(items) > 0 {
+			pending.applyTo(&items[len(items)-1])
+		}
+		return
+	}
+	location := rxAmount.FindStringIndex(line)
+	if location == nil {
+		return
+	}
+	price, priceConfidence := parseAmount(line)
+	description := strings.TrimSpace(line[:location[0]])
+	descriptionConfidence := 0.9
+
+	if amount, isDiscount := strings.CutPrefix(price, "-"); isDiscount {
+		cleaned := clean(rxDescriptionChars, description)
+		if cleaned != description {
+			descriptionConfidence = 0.6
+		}
+		if cleaned == "" {
+			cleaned = "Discount"
+			descriptionConfidence = 0.3
+		}
+		result.Receipt.Discounts = append(result.Receipt.Discounts, receipt.Discount{Description: cleaned, Amount: amount})
+		result.Confidence.Discounts = append(result.Confidence.Discounts, DiscountConfidence{Description: descriptionConfidence, Amount: priceConfidence})
+		return
+	}
+
+	item := receipt.Item{Price: price}
+	match := rxInlineQuantity.FindStringSubmatch(description)
+	if match != nil {
+		description = match[2]
+	}
+	cleaned := clean(rxDescriptionChars, description)
+	if cleaned != description {
+		descriptionConfidence = 0.6
+	}
+	if cleaned == "" {
+		return
+	}
+	item.ShortDescription = cleaned
+	if match != nil {
+		item.Quantity = match[1]
+		item.UnitPrice = match[3]
+		if item.Validate() != nil {
+			item.UnitPrice = ""
+			priceConfidence *= 0.8
+		}
+	}
+	pending.applyTo(&item)
+	result.Receipt.Items = append(result.Receipt.Items, item)
+	result.Confidence.Items = append(result.Confidence.Items, ItemConfidence{ShortDescription: descriptionConfidence, Price: priceConfidence})
+}
+
+func parseAmount(line string) (string, float64) {
+	match := rxAmount.FindStringSubmatch(line)
+	if match == nil {
+		return "", 0
+	}
+	confidence := 1.0
+	if strings.Contains(match[0], "$") || strings.Contains(match[2], ",") {
+		confidence = 0.95
+	}
+	whole := strings.ReplaceAll(match[2], ",", "")
+	return fmt.Sprintf("%s%d.%s", match[1], atoi(whole), match[3]), confidence
+}
+
+func clean(disallowed *regexp.Regexp, value string) string {
+	return strings.TrimSpace(disallowed.ReplaceAllString(apostrophes.Replace(value), " "))
+}
+
+func cents(amount string) int {
+	unsigned, negative := strings.CutPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(unsigned, ".")
+	total := atoi(whole)*100 + atoi(fraction)
+	if negative {
+		return -total
+	}
+	return total
+}
+
+func formatCents(total int) string {
+	sign := ""
+	if total < 0 {
+		sign = "-"
+		total = -total
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, total/100, total%100)
+}
+
+func atoi(value string) int {
+	parsed, _ := strconv.Atoi(value)
+	return parsed
+}
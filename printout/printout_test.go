@@ -0,0 +1,219 @@
+package printout
+
+import (
+	"strings"
+	"testing"
+)
+
+const targetPrintout = `
+        TARGET
+   1234 MAIN ST, ANYTOWN
+   (555) 123-4567
+
+01/01/2022   01:01 PM
+
+Mountain Dew 12PK          6.49 T
+Emils Cheese Pizza        12.25 T
+Knorr Creamy Chicken       1.26 T
+Doritos Nacho Cheese       3.35 T
+Klarbrunn 12-PK 12 FL OZ  12.00 T
+SUBTOTAL                  35.35
+TAX                        0.00
+TOTAL                    $35.35
+VISA                      35.35
+CHANGE DUE                 0.00
+`
+
+func TestParseTargetPrintout(t *testing.T) {
+	result := Parse(targetPrintout)
+	parsed := result.Receipt
+	if parsed.Retailer != "TARGET" || parsed.PurchaseDate != "2022-01-01" || parsed.PurchaseTime != "13:01" || parsed.Total != "35.35" {
+		t.Errorf("Should parse the receipt fields not %+v", parsed)
+	}
+	if len(parsed.Items) != 5 {
+		t.Fatalf("Should parse 5 items not %+v", parsed.Items)
+	}
+	if parsed.Items[4].ShortDescription != "Klarbrunn 12-PK 12 FL OZ" || parsed.Items[4].Price != "12.00" {
+		t.Errorf("Should parse the Klarbrunn item not %+v", parsed.Items[4])
+	}
+	if err := parsed.Validate(); err != nil {
+		t.Errorf("Should parse a valid receipt ... %s", err)
+	}
+	if result.Confidence.Retailer != 0.9 || result.Confidence.PurchaseTime != 0.95 || result.Confidence.Total != 0.95 {
+		t.Errorf("Should have high confidence not %+v", result.Confidence)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Should have no warnings not %v", result.Warnings)
+	}
+}
+
+func TestParseLowConfidenceFields(t *testing.T) {
+	text := strings.Join([]string{
+		"Welcome to Trader Joe's",
+		"03.04.22 18:45",
+		"BANANAS 0.99",
+		"2 @ 1.50",
+		"Milk 1/2 gal 3.00",
+		"Coupon -0.50",
+		"Amount Due 3.49",
+	}, "\n")
+	result := Parse(text)
+	parsed := result.Receipt
+	if parsed.Retailer != "Trader Joes" || result.Confidence.Retailer != 0.6 {
+		t.Errorf("Should clean the retailer with lower confidence not %s %v", parsed.Retailer, result.Confidence.Retailer)
+	}
+	if parsed.PurchaseDate != "2022-04-03" || result.Confidence.PurchaseDate >= 0.8 {
+		t.Errorf("Should read an ambiguous dotted date as day first with low confidence not %s %v", parsed.PurchaseDate, result.Confidence.PurchaseDate)
+	}
+	if parsed.PurchaseTime != "18:45" || result.Confidence.PurchaseTime != 1 {
+		t.Errorf("Should parse a 24 hour time not %s", parsed.PurchaseTime)
+	}
+	if len(parsed.Items) != 2 || parsed.Items[1].ShortDescription != "Milk 1 2 gal" || result.Confidence.Items[1].ShortDescription != 0.6 {
+		t.Errorf("Should clean descriptions not %+v %+v", parsed.Items, result.Confidence.Items)
+	}
+	if parsed.Items[1].Quantity != "2" || parsed.Items[1].UnitPrice != "1.50" || parsed.Items[0].Quantity != "" {
+		t.Errorf("Should apply the quantity line to the item it reconciles with not %+v", parsed.Items)
+	}
+	if len(parsed.Discounts) != 1 || parsed.Discounts[0].Description != "Coupon" || parsed.Discounts[0].Amount != "0.50" {
+		t.Errorf("Should read negative lines as discounts not %+v", parsed.Discounts)
+	}
+	if parsed.Total != "3.49" || result.Confidence.Total != 0.8 {
+		t.Errorf("Should lower the confidence of an amount due not %s %v", parsed.Total, result.Confidence.Total)
+	}
+	if result.Confidence.Min() != 0.6 {
+		t.Errorf("Should have a minimum confidence of 0.6 not %v", result.Confidence.Min())
+	}
+	if err := parsed.Validate(); err != nil {
+		t.Errorf("Should parse a valid receipt ... %s", err)
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	expected := map[string]string{
+		"2022-01-02":        "2022-01-02",
+		"Date: 2022/1/2":    "2022-01-02",
+		"Jan 2, 2022":       "2022-01-02",
+		"January 2 2022":    "2022-01-02",
+		"12/25/2022":        "2022-12-25",
+		"25/12/2022":        "2022-12-25",
+		"12/25/22":          "2022-12-25",
+		"02/30/2022":        "",
+		"no date here 1.25": "",
+	}
+	for line, date := range expected {
+		parsed, _ := parseDate(line)
+		if parsed != date {
+			t.Errorf("%q should parse as %q not %q", line, date, parsed)
+		}
+	}
+}
+
+func TestParseTimes(t *testing.T) {
+	expected := map[string]string{
+		"08:13":       "08:13",
+		"8:13 am":     "08:13",
+		"12:05 AM":    "00:05",
+		"12:05 PM":    "12:05",
+		"11:59:30 pm": "23:59",
+		"25:00":       "",
+		"13:00 PM":    "",
+	}
+	for line, clock := range expected {
+		parsed, _ := parseTime(line)
+		if parsed != clock {
+			t.Errorf("%q should parse as %q not %q", line, clock, parsed)
+		}
+	}
+}
+
+func TestParseWithoutTotal(t *testing.T) {
+	result := Parse("CORNER SHOP\n2022-03-20 14:33\nGatorade 2.25\nGatorade 2.25\n")
+	if result.Receipt.Total != "4.50" || result.Confidence.Total != 0.3 {
+		t.Errorf("Should fall back to the sum of the items not %s %v", result.Receipt.Total, result.Confidence.Total)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Should warn about the missing total not %v", result.Warnings)
+	}
+}
+
+func TestParseInlineQuantity(t *testing.T) {
+	result := Parse("CORNER SHOP\n2022-03-20 14:33\n3 x Gatorade @ 0.75   2.25\n2 x Water @ 1.00   3.00\nTOTAL 5.25\n")
+	items := result.Receipt.Items
+	if len(items) != 2 || items[0].ShortDescription != "Gatorade" || items[0].Quantity != "3" || items[0].UnitPrice != "0.75" {
+		t.Fatalf("Should read the inline quantity not %+v", items)
+	}
+	if items[1].Quantity != "2" || items[1].UnitPrice != "" || result.Confidence.Items[1].Price != 0.8 {
+		t.Errorf("Should drop a unit price that does not reconcile not %+v %+v", items[1], result.Confidence.Items[1])
+	}
+}
+
+func TestParseSubtotalTaxAndTip(t *testing.T) {
+	text := strings.Join([]string{
+		"CORNER CAFE",
+		"2022-05-06 12:30",
+		"Sandwich 8.00",
+		"Soup 4.00",
+		"Subtotal 12.00",
+		"Sales Tax 0.96",
+		"Tip 2.00",
+		"TOTAL 14.96",
+	}, "\n")
+	result := Parse(text)
+	parsed := result.Receipt
+	if parsed.Subtotal != "12.00" || parsed.Tax != "0.96" || parsed.Tip != "2.00" || parsed.Total != "14.96" {
+		t.Errorf("Should read the subtotal, tax and tip lines not %+v", parsed)
+	}
+	if len(parsed.Items) != 2 || len(result.Warnings) != 0 {
+		t.Errorf("Should reconcile the total with the subtotal plus tax and tip not %+v %v", parsed.Items, result.Warnings)
+	}
+	if err := parsed.Validate(); err != nil {
+		t.Errorf("Should parse a valid receipt ... %s", err)
+	}
+
+	mismatched := Parse(strings.Replace(text, "TOTAL 14.96", "TOTAL 12.00", 1))
+	if mismatched.Confidence.Total != 0.5 || len(mismatched.Warnings) != 1 || !strings.Contains(mismatched.Warnings[0], "subtotal plus tax and tip (14.96)") {
+		t.Errorf("Should warn that the total does not match not %v %v", mismatched.Confidence.Total, mismatched.Warnings)
+	}
+}
+
+func TestParseUnicodeText(t *testing.T) {
+	text := strings.Join([]string{
+		"Café Olé",
+		"2022-05-06 12:30",
+		"Crème brûlée 4.50",
+		"TOTAL 4.50",
+	}, "\n")
+	result := Parse(text)
+	if result.Receipt.Retailer != "Café Olé" || result.Confidence.Retailer != 0.9 {
+		t.Errorf("Should keep accented letters in the retailer not %q %v", result.Receipt.Retailer, result.Confidence.Retailer)
+	}
+	if len(result.Receipt.Items) != 1 || result.Receipt.Items[0].ShortDescription != "Crème brûlée" || result.Confidence.Items[0].ShortDescription != 0.9 {
+		t.Errorf("Should keep accented letters in descriptions not %+v %+v", result.Receipt.Items, result.Confidence.Items)
+	}
+}
+
+func TestParseNetNegativeReceipt(t *testing.T) {
+	expected := map[string]string{
+		"Gatorade 1.00\nCoupon -2.50\n": "-1.50",
+		"Gatorade 1.00\nCoupon -1.50\n": "-0.50",
+	}
+	for lines, total := range expected {
+		result := Parse("CORNER SHOP\n2022-03-20 14:33\n" + lines)
+		if result.Receipt.Total != total {
+			t.Errorf("Should total %q as %s not %s", lines, total, result.Receipt.Total)
+		}
+	}
+	matched := Parse("CORNER SHOP\n2022-03-20 14:33\nGatorade 1.00\nCoupon -2.50\nTOTAL -1.50\n")
+	if matched.Receipt.Total != "-1.50" || len(matched.Warnings) != 0 {
+		t.Errorf("Should match a negative total line not %s %v", matched.Receipt.Total, matched.Warnings)
+	}
+}
+
+func TestParseNonLatinRetailer(t *testing.T) {
+	for _, retailer := range []string{"ДИКСИ", "ローソン"} {
+		result := Parse(retailer + "\n2022-05-06 12:30\nOnigiri 1.50\nTOTAL 1.50\n")
+		if result.Receipt.Retailer != retailer || result.Confidence.Retailer != 0.9 {
+			t.Errorf("Should read %s as the retailer not %q %v", retailer, result.Receipt.Retailer, result.Confidence.Retailer)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package receipt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type BonusRule struct {
+	ID              string  `json:"id"`
+	Description     string  `json:"description,omitempty"`
+	Retailer        string  `json:"retailer,omitempty"`
+	RetailerPattern string  `json:"retailerPattern,omitempty"`
+	RetailerID      string  `json:"retailerId,omitempty"`
+	Item            string  `json:"item,omitempty"`
+	Category        string  `json:"category,omitempty"`
+	Multiplier      float64 `json:"multiplier,omitempty"`
+	Points          int     `json:"points,omitempty"`
+
+	retailerPattern *regexp.Regexp
+	itemPattern     *regexp.Regexp
+}
+
+type Bonuses struct {
+	Categories map[string][]string `json:"categories,omitempty"`
+	Rules      []BonusRule         `json:"rules"`
+
+	categories map[string][]*regexp.Regexp
+}
+
+func LoadBonuses(filename string) (*Bonuses, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading bonus rules: %w", err)
+	}
+	bonuses := &Bonuses{}
+	err2 := json.Unmarshal(data, bonuses)
+	if err2 != nil {
+		return nil, fmt.Errorf("Error unmarshaling bonus rules: %w", err2)
+	}
+	err3 := bonuses.Compile()
+	if err3 != nil {
+		return nil, err3
+	}
+	return bonuses, nil
+}
+
+func (bonuses *Bonuses) Compile() error {
+	bonuses.categories = make(map[string][]*regexp.Regexp)
+	for category, patterns := range bonuses.Categories {
+		for _, pattern := range patterns {
+			compiled, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern for category %s: %w", category, err)
+			}
+			bonuses.categories[category] = append(bonuses.categories[category], compiled)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for index := range bonuses.Rules {
+		rule := &bonuses.Rules[index]
+		if rule.ID == "" || ids[rule.ID] {
+			return fmt.Errorf("bonus rule %d needs a unique id", index)
+		}
+		ids[rule.ID] = true
+		err2 := rule.compile(bonuses.categories)
+		if err2 != nil {
+			return fmt.Errorf("bonus rule %s %w", rule.ID, err2)
+		}
+	}
+	return nil
+}
+
+func (rule *BonusRule) compile(categories map[string][]*regexp.Regexp) error {
+	if rule.Multiplier == 0 && rule.Points == 0 {
+		return fmt.Errorf("needs a multiplier or points")
+	}
+	if rule.Multiplier < 0 || math.IsInf(rule.Multiplier, 0) || math.IsNaN(rule.Multiplier) {
+		return fmt.Errorf("has an invalid multiplier")
+	}
+	if rule.Category != "" && categories[rule.Category] == nil {
+		return fmt.Errorf("uses unknown category %q", rule.Category)
+	}
+	if rule.RetailerPattern != "" {
+		var err error
+		rule.retailerPattern, err = regexp.Compile("(?i)" + rule.RetailerPattern)
+		if err != nil {
+			return fmt.Errorf("has an invalid retailer pattern: %w", err)
+		}
+	}
+	if rule.Item != "" {
+		var err2 error
+		rule.itemPattern, err2 = regexp.Compile("(?i)" + rule.Item)
+		if err2 != nil {
+			return fmt.Errorf("has an invalid item pattern: %w", err2)
+		}
+	}
+	return nil
+}
+
+func (rule *BonusRule) RuleName() string {
+	return "bonus_" + rule.ID
+}
+
+func (rule *BonusRule) PerItem() bool {
+	return rule.Item != "" || rule.Category != ""
+}
+
+func (rule *BonusRule) label() string {
+	if rule.Description != "" {
+		return rule.Description
+	}
+	return rule.ID
+}
+
+func (rule *BonusRule) matchesRetailer(receipt *Receipt) bool {
+	if rule.Retailer != "" && !strings.EqualFold(strings.TrimSpace(receipt.Retailer), strings.TrimSpace(rule.Retailer)) {
+		return false
+	}
+	if rule.retailerPattern != nil && !rule.retailerPattern.MatchString(receipt.Retailer) {
+		return false
+	}
+	if rule.RetailerID != "" && receipt.RetailerID != rule.RetailerID {
+		return false
+	}
+	return true
+}
+
+func (rule *BonusRule) matchesItem(item *Item, categories map[string][]*regexp.Regexp) bool {
+	if rule.itemPattern != nil && !rule.itemPattern.MatchString(item.ShortDescription) {
+		return false
+	}
+	if rule.Category != "" {
+		for _, pattern := range categories[rule.Category] {
+			if pattern.MatchString(item.ShortDescription) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type basePoints struct {
+	total int
+	items map[int]int
+}
+
+// pointsOf sums the points the ruleset's own rules gave, so bonuses and
+// campaigns never multiply each other.
+func pointsOf(results []RuleResult) basePoints {
+	base := basePoints{items: make(map[int]int)}
+	for _, result := range results {
+		base.total += result.Points
+		if result.Item != nil {
+			base.items[*result.Item] += result.Points
+		}
+	}
+	return base
+}
+
+func (rule *BonusRule) score(receipt *Receipt, base basePoints, categories map[string][]*regexp.Regexp, name string, reason string) []RuleResult {
+	if !rule.matchesRetailer(receipt) {
+		return nil
+	}
+	if !rule.PerItem() {
+		points := rule.Points + extraPoints(base.total, rule.Multiplier)
+		return []RuleResult{{Rule: name, Points: points, Message: fmt.Sprintf("%d %s", points, reason)}}
+	}
+	var results []RuleResult
+	for index := range receipt.Items {
+		item := &receipt.Items[index]
+		if !rule.matchesItem(item, categories) {
+			continue
+		}
+		points := rule.Points + extraPoints(base.items[index], rule.Multiplier)
+		message := fmt.Sprintf("%d %s (%s | %s)", points, reason, item.ShortDescription, item.Price)
+		results = append(results, RuleResult{Rule: name, Item: &index, Points: points, Message: message})
+	}
+	return results
+}
+
+func (bonuses *Bonuses) apply(receipt *Receipt, base basePoints) []RuleResult {
+	var results []RuleResult
+	for index := range bonuses.Rules {
+		rule := &bonuses.Rules[index]
+		results = append(results, rule.score(receipt, base, bonuses.categories, rule.RuleName(), "bonus points for "+rule.label())...)
+	}
+	return results
+}
+
+func extraPoints(points int, multiplier float64) int {
+	if multiplier == 0 {
+		return 0
+	}
+	return int(math.Round(float64(points) * (multiplier - 1)))
+}
@@ -0,0 +1,110 @@
+package receipt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func bonusReceipt() Receipt {
+	return Receipt{
+		Retailer:     "Target",
+		RetailerID:   "target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Organic Milk", Price: "3.00"},
+			{ShortDescription: "Bread", Price: "2.50"},
+		},
+		Total: "5.50",
+	}
+}
+
+func TestBonusRules(t *testing.T) {
+	bonuses := &Bonuses{
+		Categories: map[string][]string{"dairy": {`\bmilk\b`, `cheese`}},
+		Rules: []BonusRule{
+			{ID: "target_double", RetailerID: "target", Multiplier: 2, Description: "Target double points"},
+			{ID: "dairy", Category: "dairy", Points: 10},
+			{ID: "milk_triple", Retailer: " target ", Item: "^organic", Multiplier: 3},
+			{ID: "walgreens", RetailerPattern: "^walgreen", Points: 100},
+		},
+	}
+	err := bonuses.Compile()
+	if err != nil {
+		t.Fatalf("Should compile the bonus rules ... %s", err)
+	}
+
+	stored := bonusReceipt()
+	base := StandardRuleset.Evaluate(context.Background(), &stored)
+	basePoints, _ := SummarizeRules(base)
+	milkPoints := 0
+	for _, result := range base {
+		if result.Item != nil && *result.Item == 0 {
+			milkPoints += result.Points
+		}
+	}
+
+	results := StandardRuleset.WithBonuses(bonuses).Evaluate(context.Background(), &stored)
+	bonusResults := results[len(base):]
+	if len(bonusResults) != 3 {
+		t.Fatalf("Should add three bonus entries not %+v", bonusResults)
+	}
+	if bonusResults[0].Rule != "bonus_target_double" || bonusResults[0].Points != basePoints || bonusResults[0].Item != nil {
+		t.Errorf("Should double the base points not %+v", bonusResults[0])
+	}
+	if !strings.HasSuffix(bonusResults[0].Message, "bonus points for Target double points") {
+		t.Errorf("Should describe the bonus not %q", bonusResults[0].Message)
+	}
+	if bonusResults[1].Rule != "bonus_dairy" || bonusResults[1].Points != 10 || *bonusResults[1].Item != 0 {
+		t.Errorf("Should give the dairy item a flat bonus not %+v", bonusResults[1])
+	}
+	if bonusResults[1].Message != "10 bonus points for dairy (Organic Milk | 3.00)" {
+		t.Errorf("Should name the item not %q", bonusResults[1].Message)
+	}
+	if bonusResults[2].Rule != "bonus_milk_triple" || bonusResults[2].Points != 2*milkPoints {
+		t.Errorf("Should triple only the item's own points not %+v", bonusResults[2])
+	}
+
+	plain := StandardRuleset.Evaluate(context.Background(), &stored)
+	if len(plain) != len(base) {
+		t.Errorf("Should leave the ruleset without bonuses unchanged")
+	}
+}
+
+func TestBonusRulesRejectMistakes(t *testing.T) {
+	invalid := map[string]Bonuses{
+		"duplicate id":     {Rules: []BonusRule{{ID: "a", Points: 1}, {ID: "a", Points: 2}}},
+		"no effect":        {Rules: []BonusRule{{ID: "a", Retailer: "Target"}}},
+		"unknown category": {Rules: []BonusRule{{ID: "a", Category: "dairy", Points: 1}}},
+		"bad pattern":      {Rules: []BonusRule{{ID: "a", Item: "(milk", Points: 1}}},
+		"bad category":     {Categories: map[string][]string{"dairy": {"[milk"}}},
+		"negative":         {Rules: []BonusRule{{ID: "a", Multiplier: -1}}},
+	}
+	for name, bonuses := range invalid {
+		if err := bonuses.Compile(); err == nil {
+			t.Errorf("Should reject %s", name)
+		}
+	}
+}
+
+func TestLoadBonuses(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "bonuses.json")
+	os.WriteFile(filename, []byte(`{"rules": [{"id": "target", "retailer": "Target", "points": 50}]}`), 0o644)
+	bonuses, err := LoadBonuses(filename)
+	if err != nil {
+		t.Fatalf("Should load the bonus rules ... %s", err)
+	}
+	stored := bonusReceipt()
+	stored.Retailer = "TARGET"
+	results := StandardRuleset.WithBonuses(bonuses).Evaluate(context.Background(), &stored)
+	if last := results[len(results)-1]; last.Rule != "bonus_target" || last.Points != 50 {
+		t.Errorf("Should match the retailer case-insensitively not %+v", last)
+	}
+	_, err2 := LoadBonuses(filepath.Join(t.TempDir(), "missing.json"))
+	if err2 == nil {
+		t.Errorf("Should fail for a missing file")
+	}
+}
@@ -0,0 +1,87 @@
+package receipt
+
+import (
+	"fmt"
+	"time"
+)
+
+type Campaign struct {
+	BonusRule
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	MinTotal string    `json:"minTotal,omitempty"`
+	Currency string    `json:"currency,omitempty"`
+	MinItems int       `json:"minItems,omitempty"`
+}
+
+func (campaign *Campaign) Compile() error {
+	if campaign.ID == "" {
+		return fmt.Errorf("campaign needs an id")
+	}
+	if campaign.Start.IsZero() || !campaign.End.After(campaign.Start) {
+		return fmt.Errorf("campaign %s needs a start before its end", campaign.ID)
+	}
+	if campaign.Category != "" {
+		return fmt.Errorf("campaign %s cannot use categories, match items with a pattern", campaign.ID)
+	}
+	currency, known := LookupCurrency(campaign.Currency)
+	if !known {
+		return fmt.Errorf("campaign %s has an unknown currency %q", campaign.ID, campaign.Currency)
+	}
+	if campaign.MinTotal != "" && !currency.ValidAmount(campaign.MinTotal) {
+		return fmt.Errorf("campaign %s has an invalid minTotal %q for %s", campaign.ID, campaign.MinTotal, currency.Code)
+	}
+	err2 := campaign.compile(nil)
+	if err2 != nil {
+		return fmt.Errorf("campaign %s %w", campaign.ID, err2)
+	}
+	return nil
+}
+
+func (campaign *Campaign) RuleName() string {
+	return "campaign_" + campaign.ID
+}
+
+// Active reports whether the receipt was purchased between the campaign's
+// start, inclusive, and its end.
+func (campaign *Campaign) Active(receipt *Receipt) bool {
+	instant, known := receipt.localPurchaseTime()
+	if !known {
+		var err error
+		instant, err = receipt.PurchaseInstant(receipt.TimeZone)
+		if err != nil {
+			return false
+		}
+	}
+	return !instant.Before(campaign.Start) && instant.Before(campaign.End)
+}
+
+// eligible compares the minTotal with the receipt's total in the campaign's
+// currency, either as given or converted by the ruleset. Receipts in other
+// currencies never reach it.
+func (campaign *Campaign) eligible(receipt *Receipt, scored *Receipt) bool {
+	if !campaign.Active(receipt) || len(receipt.Items) < campaign.MinItems {
+		return false
+	}
+	if campaign.MinTotal == "" {
+		return true
+	}
+	currency, _ := LookupCurrency(campaign.Currency)
+	for _, candidate := range []*Receipt{receipt, scored} {
+		if candidate.currency().Code == currency.Code {
+			return currency.Minor(candidate.Total) >= currency.Minor(campaign.MinTotal)
+		}
+	}
+	return false
+}
+
+// applyCampaigns scores the campaigns recorded on a receipt, so later changes
+// to the campaigns leave stored receipts alone.
+func applyCampaigns(campaigns []Campaign, scored *Receipt, base basePoints) []RuleResult {
+	var results []RuleResult
+	for index := range campaigns {
+		campaign := &campaigns[index]
+		results = append(results, campaign.score(scored, base, nil, campaign.RuleName(), "points from campaign "+campaign.ID)...)
+	}
+	return results
+}
@@ -0,0 +1,120 @@
+package receipt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCampaigns(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	campaigns := []Campaign{
+		{BonusRule: BonusRule{ID: "new-year", Multiplier: 2}, Start: start, End: start.AddDate(0, 0, 2)},
+		{BonusRule: BonusRule{ID: "milk", Item: "milk", Points: 5}, Start: start, End: start.AddDate(0, 1, 0), MinTotal: "5.00"},
+		{BonusRule: BonusRule{ID: "big-baskets", Points: 50}, Start: start, End: start.AddDate(0, 1, 0), MinItems: 3},
+		{BonusRule: BonusRule{ID: "last-year", Points: 50}, Start: start.AddDate(-1, 0, 0), End: start},
+	}
+	for index := range campaigns {
+		err := campaigns[index].Compile()
+		if err != nil {
+			t.Fatalf("Should compile campaign %s ... %s", campaigns[index].ID, err)
+		}
+	}
+
+	stored := bonusReceipt()
+	base := StandardRuleset.Evaluate(context.Background(), &stored)
+	basePoints, _ := SummarizeRules(base)
+	ruleset := StandardRuleset.WithCampaigns(campaigns)
+	stored.Campaigns = ruleset.ActiveCampaigns(&stored)
+	results := StandardRuleset.Evaluate(context.Background(), &stored)
+	campaignResults := results[len(base):]
+	if len(campaignResults) != 2 {
+		t.Fatalf("Should apply two campaigns not %+v", campaignResults)
+	}
+	if campaignResults[0].Rule != "campaign_new-year" || campaignResults[0].Points != basePoints {
+		t.Errorf("Should double the points not %+v", campaignResults[0])
+	}
+	if !strings.Contains(campaignResults[0].Message, "points from campaign new-year") {
+		t.Errorf("Should name the campaign not %q", campaignResults[0].Message)
+	}
+	if campaignResults[1].Message != "5 points from campaign milk (Organic Milk | 3.00)" {
+		t.Errorf("Should give the item its bonus not %q", campaignResults[1].Message)
+	}
+
+	stored.PurchaseDate = "2022-01-03"
+	stored.Campaigns = ruleset.ActiveCampaigns(&stored)
+	later := ruleset.Evaluate(context.Background(), &stored)
+	if len(later) != len(base)+1 {
+		t.Errorf("Should end the campaign at its end not %+v", later[len(base):])
+	}
+}
+
+func TestCampaignUsesPurchaseInstant(t *testing.T) {
+	campaign := Campaign{
+		BonusRule: BonusRule{ID: "midnight", Points: 1},
+		Start:     time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+		End:       time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	stored := Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "20:00", TimeZone: "America/New_York"}
+	if !campaign.Active(&stored) {
+		t.Errorf("Should read the purchase time in the receipt's time zone")
+	}
+	stored.PurchasedAt = "2022-01-01T20:00:00Z"
+	if campaign.Active(&stored) {
+		t.Errorf("Should prefer the stored purchase instant")
+	}
+}
+
+func TestCampaignRejectsMistakes(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	invalid := map[string]Campaign{
+		"no id":        {BonusRule: BonusRule{Points: 1}, Start: start, End: start.Add(time.Hour)},
+		"no start":     {BonusRule: BonusRule{ID: "a", Points: 1}, End: start},
+		"end first":    {BonusRule: BonusRule{ID: "a", Points: 1}, Start: start, End: start.Add(-time.Hour)},
+		"no effect":    {BonusRule: BonusRule{ID: "a"}, Start: start, End: start.Add(time.Hour)},
+		"category":     {BonusRule: BonusRule{ID: "a", Category: "dairy", Points: 1}, Start: start, End: start.Add(time.Hour)},
+		"bad minTotal": {BonusRule: BonusRule{ID: "a", Points: 1}, Start: start, End: start.Add(time.Hour), MinTotal: "ten"},
+		"yen cents":    {BonusRule: BonusRule{ID: "a", Points: 1}, Start: start, End: start.Add(time.Hour), MinTotal: "20.00", Currency: "JPY"},
+		"no cents":     {BonusRule: BonusRule{ID: "a", Points: 1}, Start: start, End: start.Add(time.Hour), MinTotal: "20"},
+		"currency":     {BonusRule: BonusRule{ID: "a", Points: 1}, Start: start, End: start.Add(time.Hour), Currency: "XYZ"},
+	}
+	for name, campaign := range invalid {
+		if err := campaign.Compile(); err == nil {
+			t.Errorf("Should reject %s", name)
+		}
+	}
+}
+
+func TestCampaignMinTotalCurrency(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	campaigns := []Campaign{
+		{BonusRule: BonusRule{ID: "dollars", Points: 5}, Start: start, End: start.AddDate(0, 1, 0), MinTotal: "20.00"},
+		{BonusRule: BonusRule{ID: "yen", Points: 5}, Start: start, End: start.AddDate(0, 1, 0), MinTotal: "2000", Currency: "JPY"},
+	}
+	for index := range campaigns {
+		err := campaigns[index].Compile()
+		if err != nil {
+			t.Fatalf("Should compile campaign %s ... %s", campaigns[index].ID, err)
+		}
+	}
+	active := func(ruleset *Ruleset, total string) string {
+		stored := Receipt{PurchaseDate: "2022-01-05", PurchaseTime: "12:00", Items: []Item{{ShortDescription: "Tea", Price: total}}, Total: total, Currency: "JPY"}
+		var ids []string
+		for _, campaign := range ruleset.WithCampaigns(campaigns).ActiveCampaigns(&stored) {
+			ids = append(ids, campaign.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if ids := active(StandardRuleset, "500"); ids != "" {
+		t.Errorf("Should not read a 500 yen total as 500 dollars ... %s", ids)
+	}
+	if ids := active(StandardRuleset, "3000"); ids != "yen" {
+		t.Errorf("Should compare yen totals with the yen campaign only ... %s", ids)
+	}
+	converted := StandardRuleset.WithCurrencies(&CurrencyConfig{Rates: map[string]float64{"JPY": 0.01}})
+	if ids := active(converted, "3000"); ids != "dollars,yen" {
+		t.Errorf("Should convert the total for the dollar campaign ... %s", ids)
+	}
+}
@@ -0,0 +1,29 @@
+package receipt
+
+import "fmt"
+
+const CapRule = "points_cap"
+
+// AwardedReason explains a cap on a receipt that got all its points, should
+// the rules give it more when it is scored again.
+const AwardedReason = "the points awarded when the receipt was stored"
+
+type PointsCap struct {
+	Limit  int    `json:"limit" xml:"limit"`
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// Apply holds the points back to the limit the receipt was awarded when it was
+// stored, as one entry after all the rules.
+func (pointsCap *PointsCap) Apply(results []RuleResult) []RuleResult {
+	if pointsCap == nil {
+		return results
+	}
+	total, _ := SummarizeRules(results)
+	if total <= pointsCap.Limit {
+		return results
+	}
+	capped := pointsCap.Limit - total
+	message := fmt.Sprintf("%d points capped by %s", capped, pointsCap.Reason)
+	return append(results, RuleResult{Rule: CapRule, Points: capped, Message: message})
+}
@@ -0,0 +1,31 @@
+package receipt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPointsCap(t *testing.T) {
+	stored := bonusReceipt()
+	results := StandardRuleset.Evaluate(context.Background(), &stored)
+	points, _ := SummarizeRules(results)
+
+	stored.Cap = &PointsCap{Limit: 10, Reason: "the daily limit of 100 points for user alice"}
+	capped := StandardRuleset.Evaluate(context.Background(), &stored)
+	cappedPoints, breakdown := SummarizeRules(capped)
+	if cappedPoints != 10 {
+		t.Errorf("Should cap the points at 10 not %d", cappedPoints)
+	}
+	last := capped[len(capped)-1]
+	if last.Rule != CapRule || last.Points != 10-points {
+		t.Errorf("Should add the capped points as the last entry not %+v", last)
+	}
+	if breakdown[len(breakdown)-1] != last.Message || last.Message[0] != '-' {
+		t.Errorf("Should show the capped points in the breakdown not %q", last.Message)
+	}
+
+	stored.Cap.Limit = points
+	if uncapped := StandardRuleset.Evaluate(context.Background(), &stored); len(uncapped) != len(results) {
+		t.Errorf("Should not add an entry under the limit")
+	}
+}
@@ -0,0 +1,209 @@
+package receipt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+type Currency struct {
+	Code       string
+	MinorUnits int
+	Thresholds *Thresholds
+}
+
+type Thresholds struct {
+	RoundAmount string `json:"roundAmount"`
+	Multiple    string `json:"multiple"`
+	PriceUnit   string `json:"priceUnit"`
+}
+
+type CurrencyConfig struct {
+	Rates      map[string]float64    `json:"rates"`
+	Thresholds map[string]Thresholds `json:"thresholds"`
+}
+
+var Currencies = map[string]Currency{
+	"AUD": {Code: "AUD", MinorUnits: 2},
+	"BHD": {Code: "BHD", MinorUnits: 3},
+	"BRL": {Code: "BRL", MinorUnits: 2},
+	"CAD": {Code: "CAD", MinorUnits: 2},
+	"CHF": {Code: "CHF", MinorUnits: 2},
+	"CLP": {Code: "CLP", MinorUnits: 0, Thresholds: &Thresholds{RoundAmount: "1000", Multiple: "250", PriceUnit: "1000"}},
+	"CNY": {Code: "CNY", MinorUnits: 2},
+	"CZK": {Code: "CZK", MinorUnits: 2},
+	"DKK": {Code: "DKK", MinorUnits: 2},
+	"EUR": {Code: "EUR", MinorUnits: 2},
+	"GBP": {Code: "GBP", MinorUnits: 2},
+	"HKD": {Code: "HKD", MinorUnits: 2},
+	"INR": {Code: "INR", MinorUnits: 2},
+	"ISK": {Code: "ISK", MinorUnits: 0, Thresholds: &Thresholds{RoundAmount: "100", Multiple: "25", PriceUnit: "100"}},
+	"JOD": {Code: "JOD", MinorUnits: 3},
+	"JPY": {Code: "JPY", MinorUnits: 0, Thresholds: &Thresholds{RoundAmount: "100", Multiple: "25", PriceUnit: "100"}},
+	"KRW": {Code: "KRW", MinorUnits: 0, Thresholds: &Thresholds{RoundAmount: "1000", Multiple: "250", PriceUnit: "1000"}},
+	"KWD": {Code: "KWD", MinorUnits: 3},
+	"MXN": {Code: "MXN", MinorUnits: 2},
+	"NOK": {Code: "NOK", MinorUnits: 2},
+	"NZD": {Code: "NZD", MinorUnits: 2},
+	"OMR": {Code: "OMR", MinorUnits: 3},
+	"PLN": {Code: "PLN", MinorUnits: 2},
+	"SEK": {Code: "SEK", MinorUnits: 2},
+	"SGD": {Code: "SGD", MinorUnits: 2},
+	"TND": {Code: "TND", MinorUnits: 3},
+	"USD": {Code: "USD", MinorUnits: 2},
+	"VND": {Code: "VND", MinorUnits: 0, Thresholds: &Thresholds{RoundAmount: "10000", Multiple: "2500", PriceUnit: "10000"}},
+	"ZAR": {Code: "ZAR", MinorUnits: 2},
+}
+
+func LookupCurrency(code string) (Currency, bool) {
+	if code == "" {
+		code = DefaultCurrency
+	}
+	currency, exists := Currencies[code]
+	return currency, exists
+}
+
+func (currency Currency) ValidAmount(amount string) bool {
+	whole, fraction, hasFraction := strings.Cut(amount, ".")
+	if !digits(whole) {
+		return false
+	}
+	if currency.MinorUnits == 0 {
+		return !hasFraction
+	}
+	return hasFraction && len(fraction) == currency.MinorUnits && digits(fraction)
+}
+
+func (currency Currency) validUnitPrice(amount string) bool {
+	whole, fraction, hasFraction := strings.Cut(amount, ".")
+	if !digits(whole) {
+		return false
+	}
+	if !hasFraction {
+		return currency.MinorUnits == 0
+	}
+	return len(fraction) >= currency.MinorUnits && len(fraction) <= currency.MinorUnits+1 && digits(fraction)
+}
+
+func digits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (currency Currency) scale() float64 {
+	return math.Pow10(currency.MinorUnits)
+}
+
+func (currency Currency) Minor(amount string) int {
+	amountFloat, _ := strconv.ParseFloat(amount, 64)
+	return int(math.Round(amountFloat * currency.scale()))
+}
+
+func (currency Currency) Format(minor int) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if currency.MinorUnits == 0 {
+		return fmt.Sprintf("%s%d", sign, minor)
+	}
+	unit := int(currency.scale())
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, currency.MinorUnits, minor%unit)
+}
+
+func (currency Currency) DefaultThresholds() Thresholds {
+	if currency.Thresholds != nil {
+		return *currency.Thresholds
+	}
+	unit := int(currency.scale())
+	return Thresholds{
+		RoundAmount: currency.Format(unit),
+		Multiple:    currency.Format(max(unit/4, 1)),
+		PriceUnit:   currency.Format(unit),
+	}
+}
+
+func (currency Currency) describe(amount string) string {
+	if currency.Code == DefaultCurrency {
+		return amount
+	}
+	return amount + " " + currency.Code
+}
+
+func LoadCurrencyConfig(filename string) (*CurrencyConfig, error) {
+	config := &CurrencyConfig{}
+	err := LoadJSON(filename, config)
+	if err != nil {
+		return nil, err
+	}
+	for code, rate := range config.Rates {
+		if _, exists := Currencies[code]; !exists {
+			return nil, fmt.Errorf("unknown currency %q in rates", code)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return nil, fmt.Errorf("rate for %s must be greater than zero", code)
+		}
+	}
+	for code, thresholds := range config.Thresholds {
+		currency, exists := Currencies[code]
+		if !exists {
+			return nil, fmt.Errorf("unknown currency %q in thresholds", code)
+		}
+		for _, amount := range []string{thresholds.RoundAmount, thresholds.Multiple, thresholds.PriceUnit} {
+			if !currency.ValidAmount(amount) || currency.Minor(amount) == 0 {
+				return nil, fmt.Errorf("invalid %s threshold (%s), use a positive amount with %d decimals", code, amount, currency.MinorUnits)
+			}
+		}
+	}
+	return config, nil
+}
+
+func (receipt *Receipt) currency() Currency {
+	currency, exists := LookupCurrency(receipt.Currency)
+	if !exists {
+		return Currencies[DefaultCurrency]
+	}
+	return currency
+}
+
+func (receipt *Receipt) Converted(rate float64) Receipt {
+	from := receipt.currency()
+	to := Currencies[DefaultCurrency]
+	convert := func(amount string) string {
+		if amount == "" {
+			return ""
+		}
+		return to.Format(int(math.Round(float64(from.Minor(amount)) / from.scale() * rate * to.scale())))
+	}
+	converted := *receipt
+	converted.Currency = DefaultCurrency
+	converted.Items = make([]Item, len(receipt.Items))
+	for index, item := range receipt.Items {
+		item.Price = convert(item.Price)
+		if item.UnitPrice != "" {
+			item.UnitPrice = convert(item.UnitPrice)
+		}
+		converted.Items[index] = item
+	}
+	converted.Discounts = make([]Discount, len(receipt.Discounts))
+	for index, discount := range receipt.Discounts {
+		discount.Amount = convert(discount.Amount)
+		converted.Discounts[index] = discount
+	}
+	converted.Subtotal = convert(receipt.Subtotal)
+	converted.Tax = convert(receipt.Tax)
+	converted.Tip = convert(receipt.Tip)
+	converted.Total = convert(receipt.Total)
+	return converted
+}
@@ -0,0 +1,134 @@
+package receipt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCurrencyFormat(t *testing.T) {
+	yen, _ := LookupCurrency("JPY")
+	dinar, _ := LookupCurrency("KWD")
+	dollar, _ := LookupCurrency("")
+	if yen.Format(1500) != "1500" || dinar.Format(1250) != "1.250" || dollar.Format(-5) != "-0.05" {
+		t.Errorf("Should format minor units not %s %s %s", yen.Format(1500), dinar.Format(1250), dollar.Format(-5))
+	}
+	if !yen.ValidAmount("1500") || yen.ValidAmount("1500.00") || !dinar.ValidAmount("1.250") || dinar.ValidAmount("1.25") {
+		t.Errorf("Should validate amounts with the currency's minor units")
+	}
+	if _, exists := LookupCurrency("XYZ"); exists {
+		t.Errorf("Should not know XYZ")
+	}
+}
+
+func TestReceiptValidateCurrency(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Lawson",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Onigiri", Price: "300", Quantity: "2", UnitPrice: "150"},
+			{ShortDescription: "Green tea", Price: "160"},
+		},
+		Total:    "460",
+		Currency: "JPY",
+	}
+	err := receipt.Validate()
+	if err != nil {
+		t.Errorf("Should be valid ... %s", err)
+	}
+
+	receipt.Total = "460.00"
+	err2 := receipt.Validate()
+	if err2 == nil || !strings.Contains(err2.Error(), "invalid format for total (460.00)") {
+		t.Errorf("Should reject decimals for JPY ... %v", err2)
+	}
+
+	kuwait := Receipt{
+		Retailer:     "Sultan Center",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []Item{{ShortDescription: "Dates", Price: "1.875", Quantity: "0.75", UnitPrice: "2.500"}},
+		Total:        "1.875",
+		Currency:     "KWD",
+	}
+	err3 := kuwait.Validate()
+	if err3 != nil {
+		t.Errorf("Should be valid ... %s", err3)
+	}
+
+	kuwait.Currency = "XYZ"
+	err4 := kuwait.Validate()
+	if err4 == nil || err4.(*ValidationError).Problems[0].Reason != "invalid_currency" {
+		t.Errorf("Should reject an unknown currency ... %v", err4)
+	}
+}
+
+func TestCurrencyPoints(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Lawson",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:01",
+		Items:        []Item{{ShortDescription: "Onigiri", Price: "500"}, {ShortDescription: "Gum", Price: "500"}},
+		Total:        "1000",
+		Currency:     "JPY",
+	}
+	points, message := receipt.PointsForRoundDollarAmount()
+	if points != 50 || message != "50 points for round amount (1000 JPY)" {
+		t.Errorf("Should use the JPY thresholds ... %d %s", points, message)
+	}
+	points2, message2 := receipt.PointsForCentsMultiple25()
+	if points2 != 25 || message2 != "25 points for being multiple of 25 (1000 JPY)" {
+		t.Errorf("Should use the JPY thresholds ... %d %s", points2, message2)
+	}
+	results := StandardRuleset.Evaluate(context.Background(), &receipt)
+	for _, result := range results {
+		if result.Rule == "item_description" && *result.Item == 1 && result.Points != 1 {
+			t.Errorf("Should score item prices per 100 JPY ... %s", result.Message)
+		}
+	}
+
+	converted := StandardRuleset.WithCurrencies(&CurrencyConfig{Rates: map[string]float64{"JPY": 0.0067}})
+	convertedPoints, breakdown := SummarizeRules(converted.Evaluate(context.Background(), &receipt))
+	if !strings.Contains(strings.Join(breakdown, "\n"), "0 points for round dollar amount (6.70)") {
+		t.Errorf("Should score the converted total ... %v", breakdown)
+	}
+	if convertedPoints >= 75 {
+		t.Errorf("Should lose the JPY round amount points not %d", convertedPoints)
+	}
+
+	configured := StandardRuleset.WithCurrencies(&CurrencyConfig{Thresholds: map[string]Thresholds{"JPY": {RoundAmount: "500", Multiple: "300", PriceUnit: "100"}}})
+	_, breakdown2 := SummarizeRules(configured.Evaluate(context.Background(), &receipt))
+	if !strings.Contains(strings.Join(breakdown2, "\n"), "0 points for being multiple of 300 (1000 JPY)") {
+		t.Errorf("Should use the configured thresholds ... %v", breakdown2)
+	}
+	if receipt.scored != nil {
+		t.Errorf("Should not change the scored receipt")
+	}
+}
+
+func TestLoadCurrencyConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		filename := filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(content), 0o644)
+		return filename
+	}
+
+	config, err := LoadCurrencyConfig(write("valid.json", `{"rates": {"EUR": 1.08}, "thresholds": {"KWD": {"roundAmount": "1.000", "multiple": "0.250", "priceUnit": "1.000"}}}`))
+	if err != nil || config.Rates["EUR"] != 1.08 || config.Thresholds["KWD"].Multiple != "0.250" {
+		t.Errorf("Should load the config ... %v", err)
+	}
+	for _, content := range []string{
+		`{"rates": {"XYZ": 1}}`,
+		`{"rates": {"EUR": 0}}`,
+		`{"thresholds": {"JPY": {"roundAmount": "1.00", "multiple": "25", "priceUnit": "100"}}}`,
+	} {
+		_, err2 := LoadCurrencyConfig(write("invalid.json", content))
+		if err2 == nil {
+			t.Errorf("Should reject %s", content)
+		}
+	}
+}
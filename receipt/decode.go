@@ -0,0 +1,254 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+type DecodeProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type DecodeError struct {
+	Problems []DecodeProblem
+}
+
+func (decodeError *DecodeError) Error() string {
+	var messages []string
+	for _, problem := range decodeError.Problems {
+		if problem.Path == "" {
+			messages = append(messages, problem.Message)
+		} else {
+			messages = append(messages, fmt.Sprintf("%s: %s", problem.Path, problem.Message))
+		}
+	}
+	return strings.Join(messages, " | ")
+}
+
+func (decodeError *DecodeError) add(path string, message string) {
+	decodeError.Problems = append(decodeError.Problems, DecodeProblem{Path: path, Message: message})
+}
+
+// RejectServerFields fails for a submitted receipt that sets any of the fields
+// the server fills in when it stores a receipt.
+func (receipt *Receipt) RejectServerFields() error {
+	decodeError := &DecodeError{}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{"retailerId", receipt.RetailerID != ""},
+		{"purchasedAt", receipt.PurchasedAt != ""},
+		{"userId", receipt.UserID != ""},
+		{"cap", receipt.Cap != nil},
+	} {
+		if field.set {
+			decodeError.add(field.name, "is set by the server")
+		}
+	}
+	if len(decodeError.Problems) > 0 {
+		return decodeError
+	}
+	return nil
+}
+
+func DecodeJSON(data []byte, strict bool) (Receipt, error) {
+	var decoded Receipt
+	if !strict {
+		err := json.Unmarshal(data, &decoded)
+		return decoded, err
+	}
+
+	decodeError := &DecodeError{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := checkJSONValue(decoder, reflect.TypeOf(decoded), "", decodeError)
+	if err != nil {
+		return decoded, err
+	}
+	_, err2 := decoder.Token()
+	if err2 != io.EOF {
+		decodeError.add("", fmt.Sprintf("unexpected data after the receipt at offset %d", decoder.InputOffset()))
+	}
+	if len(decodeError.Problems) > 0 {
+		return decoded, decodeError
+	}
+
+	err3 := json.Unmarshal(data, &decoded)
+	return decoded, err3
+}
+
+func jsonFields(t reflect.Type) (map[string]reflect.Type, []string) {
+	fields := make(map[string]reflect.Type)
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	return fields, required
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.Kind().String()
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func checkJSONValue(decoder *json.Decoder, t reflect.Type, path string, decodeError *DecodeError) error {
+	token, err := decoder.Token()
+	if err != nil {
+		decodeError.add(path, fmt.Sprintf("malformed JSON (%s)", err))
+		return decodeError
+	}
+
+	nullable := t.Kind() == reflect.Pointer
+	if nullable {
+		t = t.Elem()
+	}
+	expected := jsonTypeName(t)
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' && t.Kind() == reflect.Struct {
+			return checkJSONObject(decoder, t, path, decodeError)
+		}
+		if value == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			for index := 0; decoder.More(); index++ {
+				err2 := checkJSONValue(decoder, t.Elem(), fmt.Sprintf("%s[%d]", path, index), decodeError)
+				if err2 != nil {
+					return err2
+				}
+			}
+			_, err3 := decoder.Token()
+			if err3 != nil {
+				decodeError.add(path, fmt.Sprintf("malformed JSON (%s)", err3))
+				return decodeError
+			}
+			return nil
+		}
+		got := "object"
+		if value == '[' {
+			got = "array"
+		}
+		decodeError.add(path, fmt.Sprintf("expected %s, got %s", expected, got))
+		return skipJSONValue(decoder, path, 1, decodeError)
+	case string:
+		if expected != "string" {
+			decodeError.add(path, fmt.Sprintf("expected %s, got string", expected))
+		}
+	case json.Number:
+		if expected != "number" {
+			decodeError.add(path, fmt.Sprintf("expected %s, got number (%s)", expected, value))
+		}
+	case bool:
+		if expected != "boolean" {
+			decodeError.add(path, fmt.Sprintf("expected %s, got boolean", expected))
+		}
+	case nil:
+		if !nullable {
+			decodeError.add(path, fmt.Sprintf("expected %s, got null", expected))
+		}
+	}
+	return nil
+}
+
+func checkJSONObject(decoder *json.Decoder, t reflect.Type, path string, decodeError *DecodeError) error {
+	fields, required := jsonFields(t)
+	seen := make(map[string]bool)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			decodeError.add(path, fmt.Sprintf("malformed JSON (%s)", err))
+			return decodeError
+		}
+		key := token.(string)
+		keyPath := joinPath(path, key)
+		if seen[key] {
+			decodeError.add(keyPath, "duplicate key")
+		}
+		seen[key] = true
+
+		fieldType, known := fields[key]
+		if !known {
+			decodeError.add(keyPath, "unknown field")
+			err2 := skipJSONValue(decoder, keyPath, 0, decodeError)
+			if err2 != nil {
+				return err2
+			}
+			continue
+		}
+		err3 := checkJSONValue(decoder, fieldType, keyPath, decodeError)
+		if err3 != nil {
+			return err3
+		}
+	}
+	_, err4 := decoder.Token()
+	if err4 != nil {
+		decodeError.add(path, fmt.Sprintf("malformed JSON (%s)", err4))
+		return decodeError
+	}
+
+	for _, name := range required {
+		if !seen[name] {
+			decodeError.add(joinPath(path, name), "missing required field")
+		}
+	}
+	return nil
+}
+
+func skipJSONValue(decoder *json.Decoder, path string, depth int, decodeError *DecodeError) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			decodeError.add(path, fmt.Sprintf("malformed JSON (%s)", err))
+			return decodeError
+		}
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth <= 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package receipt
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func decodeProblems(t *testing.T, payload string) []DecodeProblem {
+	t.Helper()
+	_, err := DecodeJSON([]byte(payload), true)
+	if err == nil {
+		t.Fatalf("Should have a decode error for %s", payload)
+	}
+	decodeError, ok := err.(*DecodeError)
+	if !ok {
+		t.Fatalf("Should be a *DecodeError not %T ... %s", err, err)
+	}
+	return decodeError.Problems
+}
+
+func hasProblem(problems []DecodeProblem, path string, message string) bool {
+	for _, problem := range problems {
+		if problem.Path == path && strings.Contains(problem.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDecodeJSONExamples(t *testing.T) {
+	for _, filename := range []string{"../example1.json", "../example2.json", "../example3.json"} {
+		data, _ := os.ReadFile(filename)
+		_, err := DecodeJSON(data, true)
+		if err != nil {
+			t.Errorf("Should decode %s in strict mode ... %s", filename, err)
+		}
+	}
+}
+
+func TestDecodeJSONUnknownField(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00",
+		"items": [{"shortDescription": "Pepsi", "price": "1.00", "qty": 2}], "store": {"id": 5}}`)
+	if !hasProblem(problems, "items[0].qty", "unknown field") {
+		t.Errorf("Should report unknown field items[0].qty ... %v", problems)
+	}
+	if !hasProblem(problems, "store", "unknown field") {
+		t.Errorf("Should report unknown field store ... %v", problems)
+	}
+}
+
+func TestDecodeJSONDuplicateKey(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target", "retailer": "Walmart", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "0.00", "items": []}`)
+	if !hasProblem(problems, "retailer", "duplicate key") {
+		t.Errorf("Should report duplicate key retailer ... %v", problems)
+	}
+}
+
+func TestDecodeJSONTrailingData(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "0.00", "items": []} {"retailer": "Walmart"}`)
+	if !hasProblem(problems, "", "unexpected data after the receipt") {
+		t.Errorf("Should report trailing data ... %v", problems)
+	}
+}
+
+func TestDecodeJSONWrongTypes(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": 1.25,
+		"items": [{"shortDescription": "Pepsi", "price": 1.25}, "Dasani"]}`)
+	if !hasProblem(problems, "total", "expected string, got number (1.25)") {
+		t.Errorf("Should report wrong type for total ... %v", problems)
+	}
+	if !hasProblem(problems, "items[0].price", "expected string, got number") {
+		t.Errorf("Should report wrong type for items[0].price ... %v", problems)
+	}
+	if !hasProblem(problems, "items[1]", "expected object, got string") {
+		t.Errorf("Should report wrong type for items[1] ... %v", problems)
+	}
+}
+
+func TestDecodeJSONMissingItems(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "0.00"}`)
+	if !hasProblem(problems, "items", "missing required field") {
+		t.Errorf("Should report missing items ... %v", problems)
+	}
+	problems2 := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "0.00", "items": null}`)
+	if !hasProblem(problems2, "items", "expected array, got null") {
+		t.Errorf("Should report null items ... %v", problems2)
+	}
+}
+
+func TestDecodeJSONQuantityAndDiscounts(t *testing.T) {
+	decoded, err := DecodeJSON([]byte(`{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.75",
+		"items": [{"shortDescription": "Gatorade", "price": "2.25", "quantity": "3", "unitPrice": "0.75"}],
+		"discounts": [{"description": "Coupon", "amount": "0.50", "item": 0}]}`), true)
+	if err != nil || decoded.Items[0].Quantity != "3" || *decoded.Discounts[0].Item != 0 {
+		t.Errorf("Should decode quantities and discounts ... %v %+v", err, decoded)
+	}
+	problems := decodeProblems(t, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.75",
+		"items": [{"shortDescription": "Gatorade", "price": "2.25", "quantity": 3}],
+		"discounts": [{"description": "Coupon", "item": "0"}]}`)
+	if !hasProblem(problems, "items[0].quantity", "expected string, got number (3)") {
+		t.Errorf("Should report a numeric quantity ... %v", problems)
+	}
+	if !hasProblem(problems, "discounts[0].item", "expected number, got string") || !hasProblem(problems, "discounts[0].amount", "missing required field") {
+		t.Errorf("Should report discount problems ... %v", problems)
+	}
+}
+
+func TestDecodeJSONMalformed(t *testing.T) {
+	problems := decodeProblems(t, `{"retailer": "Target",`)
+	if !hasProblem(problems, "", "malformed JSON") {
+		t.Errorf("Should report malformed JSON ... %v", problems)
+	}
+}
+
+func TestDecodeJSONLenient(t *testing.T) {
+	decoded, err := DecodeJSON([]byte(`{"retailer": "Target", "store": 5, "total": "0.00"}`), false)
+	if err != nil {
+		t.Errorf("Lenient mode should ignore unknown fields and missing items ... %s", err)
+	}
+	if decoded.Retailer != "Target" {
+		t.Errorf("Should decode the retailer not %v", decoded)
+	}
+}
@@ -0,0 +1,225 @@
+package receipt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+const DefaultExpressionTimeout = 50 * time.Millisecond
+const maxExpressionNodes = 1000
+const maxRunningExpressions = 16
+
+var rxRuleName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type ExpressionRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Expression  string `json:"expression"`
+	PerItem     bool   `json:"perItem,omitempty"`
+
+	program *vm.Program
+}
+
+type ExpressionRules struct {
+	Timeout      string           `json:"timeout,omitempty"`
+	MemoryBudget uint             `json:"memoryBudget,omitempty"`
+	Rules        []ExpressionRule `json:"rules"`
+
+	timeout time.Duration
+	running chan struct{}
+}
+
+type expressionItem struct {
+	ShortDescription string  `expr:"shortDescription"`
+	Price            float64 `expr:"price"`
+	Quantity         float64 `expr:"quantity"`
+	UnitPrice        float64 `expr:"unitPrice"`
+}
+
+type expressionReceipt struct {
+	Retailer     string           `expr:"retailer"`
+	RetailerID   string           `expr:"retailerId"`
+	PurchaseDate string           `expr:"purchaseDate"`
+	PurchaseTime string           `expr:"purchaseTime"`
+	Weekday      string           `expr:"weekday"`
+	Day          int              `expr:"day"`
+	Hour         int              `expr:"hour"`
+	Items        []expressionItem `expr:"items"`
+	Subtotal     float64          `expr:"subtotal"`
+	Tax          float64          `expr:"tax"`
+	Tip          float64          `expr:"tip"`
+	Total        float64          `expr:"total"`
+	Currency     string           `expr:"currency"`
+}
+
+type expressionItemReceipt struct {
+	expressionReceipt
+	Item expressionItem `expr:"item"`
+}
+
+func LoadExpressionRules(filename string) (*ExpressionRules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading expression rules: %w", err)
+	}
+	rules := &ExpressionRules{}
+	err2 := json.Unmarshal(data, rules)
+	if err2 != nil {
+		return nil, fmt.Errorf("Error unmarshaling expression rules: %w", err2)
+	}
+	err3 := rules.Compile()
+	if err3 != nil {
+		return nil, err3
+	}
+	return rules, nil
+}
+
+// Compile type-checks every expression against the receipt fields, so a rule
+// that refers to an unknown field or does not give whole points never loads.
+func (rules *ExpressionRules) Compile() error {
+	rules.timeout = DefaultExpressionTimeout
+	if rules.Timeout != "" {
+		timeout, err := time.ParseDuration(rules.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid expression timeout %q", rules.Timeout)
+		}
+		rules.timeout = timeout
+	}
+	rules.running = make(chan struct{}, maxRunningExpressions)
+	names := make(map[string]bool)
+	for index := range rules.Rules {
+		rule := &rules.Rules[index]
+		if !rxRuleName.MatchString(rule.Name) || names[rule.Name] {
+			return fmt.Errorf("expression rule %d needs a unique lower case name", index)
+		}
+		names[rule.Name] = true
+		var env any = expressionReceipt{}
+		if rule.PerItem {
+			env = expressionItemReceipt{}
+		}
+		program, err2 := expr.Compile(rule.Expression, expr.Env(env), expr.AsInt(), expr.MaxNodes(maxExpressionNodes), expr.DisableBuiltin("now"))
+		if err2 != nil {
+			return fmt.Errorf("expression rule %s: %w", rule.Name, err2)
+		}
+		if resultType := program.Node().Type(); resultType != nil && resultType.Kind() == reflect.Float64 {
+			return fmt.Errorf("expression rule %s gives fractions of points, wrap it in int()", rule.Name)
+		}
+		rule.program = program
+	}
+	return nil
+}
+
+// run stops waiting for an expression after the timeout. The memory budget
+// bounds how long an abandoned one can keep running, and no more than
+// maxRunningExpressions run at once, so slow expressions cannot pile up.
+func (rules *ExpressionRules) run(program *vm.Program, env any) (int, error) {
+	type outcome struct {
+		value any
+		err   error
+	}
+	timer := time.NewTimer(rules.timeout)
+	defer timer.Stop()
+	select {
+	case rules.running <- struct{}{}:
+	case <-timer.C:
+		return 0, fmt.Errorf("took longer than %s", rules.timeout)
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() { <-rules.running }()
+		machine := vm.VM{MemoryBudget: rules.MemoryBudget}
+		value, err := machine.Run(program, env)
+		done <- outcome{value, err}
+	}()
+	select {
+	case result := <-done:
+		if result.err != nil {
+			return 0, result.err
+		}
+		return result.value.(int), nil
+	case <-timer.C:
+		return 0, fmt.Errorf("took longer than %s", rules.timeout)
+	}
+}
+
+func (rules *ExpressionRules) receiptRule(rule ExpressionRule) Rule {
+	return Rule{Name: rule.Name, Receipt: func(receipt *Receipt) (int, string) {
+		points, err := rules.run(rule.program, receipt.expressionEnv())
+		if err != nil {
+			return 0, fmt.Sprintf("0 points for %s, it failed: %s", rule.label(), err)
+		}
+		return points, fmt.Sprintf("%d points for %s", points, rule.label())
+	}}
+}
+
+func (rules *ExpressionRules) itemRule(rule ExpressionRule) Rule {
+	return Rule{Name: rule.Name, Item: func(item *Item) (int, string) {
+		env := expressionItemReceipt{Item: item.expressionEnv()}
+		if item.receipt != nil {
+			env.expressionReceipt = item.receipt.expressionEnv()
+		}
+		points, err := rules.run(rule.program, env)
+		if err != nil {
+			return 0, fmt.Sprintf("0 points for %s (%s | %s), it failed: %s", rule.label(), item.ShortDescription, item.Price, err)
+		}
+		return points, fmt.Sprintf("%d points for %s (%s | %s)", points, rule.label(), item.ShortDescription, item.Price)
+	}}
+}
+
+func (rule *ExpressionRule) label() string {
+	if rule.Description != "" {
+		return rule.Description
+	}
+	return rule.Name
+}
+
+func amountValue(amount string) float64 {
+	value, _ := strconv.ParseFloat(amount, 64)
+	return value
+}
+
+func (item *Item) expressionEnv() expressionItem {
+	return expressionItem{
+		ShortDescription: item.ShortDescription,
+		Price:            amountValue(item.Price),
+		Quantity:         amountValue(item.Quantity),
+		UnitPrice:        amountValue(item.UnitPrice),
+	}
+}
+
+func (receipt *Receipt) expressionEnv() expressionReceipt {
+	env := expressionReceipt{
+		Retailer:     receipt.Retailer,
+		RetailerID:   receipt.RetailerID,
+		PurchaseDate: receipt.PurchaseDate,
+		PurchaseTime: receipt.PurchaseTime,
+		Subtotal:     amountValue(receipt.Subtotal),
+		Tax:          amountValue(receipt.Tax),
+		Tip:          amountValue(receipt.Tip),
+		Total:        amountValue(receipt.Total),
+		Currency:     receipt.currency().Code,
+	}
+	purchased, located := receipt.localPurchaseTime()
+	if !located {
+		var err error
+		purchased, err = time.Parse("2006-01-02 15:04", receipt.PurchaseDate+" "+receipt.PurchaseTime)
+		located = err == nil
+	}
+	if located {
+		env.Weekday = purchased.Weekday().String()
+		env.Day = purchased.Day()
+		env.Hour = purchased.Hour()
+	}
+	for index := range receipt.Items {
+		env.Items = append(env.Items, receipt.Items[index].expressionEnv())
+	}
+	return env
+}
@@ -0,0 +1,155 @@
+package receipt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func expressionReceiptExample() Receipt {
+	return Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "Klarbrunn 12-PK 12 FL OZ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+}
+
+func TestExpressionRules(t *testing.T) {
+	expressions := &ExpressionRules{Rules: []ExpressionRule{
+		{Name: "big_basket", Expression: "len(items) >= 5 && total > 20 ? 15 : 0", Description: "a big basket"},
+		{Name: "weekend", Expression: `weekday in ["Saturday", "Sunday"] && hour < 14 ? 5 : 0`},
+		{Name: "pricey_item", Expression: `item.price > 10 && retailer == "Target" ? 3 : 0`, PerItem: true},
+	}}
+	err := expressions.Compile()
+	if err != nil {
+		t.Fatalf("Should compile the expressions ... %s", err)
+	}
+	ruleset, err2 := StandardRuleset.WithExpressions(expressions)
+	if err2 != nil {
+		t.Fatalf("Should add the expressions ... %s", err2)
+	}
+
+	stored := expressionReceiptExample()
+	base, _ := SummarizeRules(StandardRuleset.Evaluate(context.Background(), &stored))
+	results := ruleset.Evaluate(context.Background(), &stored)
+	points, breakdown := SummarizeRules(results)
+	if points != base+15+5+3+3 {
+		t.Errorf("Should add 26 points to %d not %d ... %v", base, points, breakdown)
+	}
+	joined := strings.Join(breakdown, "\n")
+	for _, expected := range []string{"15 points for a big basket", "5 points for weekend", "3 points for pricey_item (Emils Cheese Pizza | 12.25)"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Should have %q in the breakdown ... %s", expected, joined)
+		}
+	}
+	if len(StandardRuleset.Rules) != 8 {
+		t.Errorf("Should leave the standard ruleset alone")
+	}
+}
+
+func TestExpressionRulesTypeCheck(t *testing.T) {
+	invalid := map[string]ExpressionRules{
+		"unknown field":     {Rules: []ExpressionRule{{Name: "a", Expression: "totl > 20 ? 1 : 0"}}},
+		"fractional points": {Rules: []ExpressionRule{{Name: "a", Expression: "total * 0.1"}}},
+		"boolean":           {Rules: []ExpressionRule{{Name: "a", Expression: "total > 20"}}},
+		"item on receipt":   {Rules: []ExpressionRule{{Name: "a", Expression: "item.price > 1 ? 1 : 0"}}},
+		"clock":             {Rules: []ExpressionRule{{Name: "a", Expression: "now().Hour()"}}},
+		"duplicate name":    {Rules: []ExpressionRule{{Name: "a", Expression: "1"}, {Name: "a", Expression: "2"}}},
+		"bad name":          {Rules: []ExpressionRule{{Name: "Big Basket", Expression: "1"}}},
+		"bad timeout":       {Timeout: "soon", Rules: []ExpressionRule{{Name: "a", Expression: "1"}}},
+	}
+	for name, expressions := range invalid {
+		if err := expressions.Compile(); err == nil {
+			t.Errorf("Should reject %s", name)
+		}
+	}
+
+	clash := &ExpressionRules{Rules: []ExpressionRule{{Name: "retailer_name", Expression: "1"}}}
+	clash.Compile()
+	_, err := StandardRuleset.WithExpressions(clash)
+	if err == nil {
+		t.Errorf("Should reject a name the ruleset already uses")
+	}
+}
+
+func TestExpressionRulesLimits(t *testing.T) {
+	slow := &ExpressionRules{Timeout: "1ms", MemoryBudget: 1e9, Rules: []ExpressionRule{
+		{Name: "slow", Expression: "len(filter(1..5000000, # % 7 == 0)) > 0 ? 1 : 0"},
+	}}
+	hungry := &ExpressionRules{MemoryBudget: 100, Rules: []ExpressionRule{
+		{Name: "hungry", Expression: "len(map(1..1000, # * 2)) > 0 ? 1 : 0"},
+	}}
+	stored := expressionReceiptExample()
+	for expected, expressions := range map[string]*ExpressionRules{"took longer than 1ms": slow, "memory budget exceeded": hungry} {
+		err := expressions.Compile()
+		if err != nil {
+			t.Fatalf("Should compile ... %s", err)
+		}
+		ruleset, _ := StandardRuleset.WithExpressions(expressions)
+		results := ruleset.Evaluate(context.Background(), &stored)
+		last := results[len(results)-1]
+		if last.Points != 0 || !strings.Contains(last.Message, expected) {
+			t.Errorf("Should give 0 points when the expression fails with %q not %+v", expected, last)
+		}
+	}
+}
+
+func TestExpressionRulesBoundAbandonedRuns(t *testing.T) {
+	slow := &ExpressionRules{Timeout: "1ns", MemoryBudget: 1e9, Rules: []ExpressionRule{
+		{Name: "slow", Expression: "len(filter(1..300000, # % 7 == 0)) > 0 ? 1 : 0"},
+	}}
+	err := slow.Compile()
+	if err != nil {
+		t.Fatalf("Should compile ... %s", err)
+	}
+	ruleset, _ := StandardRuleset.WithExpressions(slow)
+	stored := expressionReceiptExample()
+	before := runtime.NumGoroutine()
+	for range 3 * maxRunningExpressions {
+		ruleset.Evaluate(context.Background(), &stored)
+	}
+	if running := runtime.NumGoroutine() - before; running > maxRunningExpressions {
+		t.Errorf("Should keep no more than %d expressions running not %d", maxRunningExpressions, running)
+	}
+	for range maxRunningExpressions {
+		slow.running <- struct{}{}
+	}
+}
+
+func TestExpressionRulesReadPurchaseInstant(t *testing.T) {
+	stored := expressionReceiptExample()
+	stored.PurchaseDate = "2022-03-13"
+	stored.PurchaseTime = "02:30"
+	if env := stored.expressionEnv(); env.Hour != 2 || env.Weekday != "Sunday" {
+		t.Errorf("Should read the wall clock time without an instant not %d %s", env.Hour, env.Weekday)
+	}
+	stored.PurchasedAt = "2022-03-13T03:30:00-04:00"
+	if env := stored.expressionEnv(); env.Hour != 3 || env.Day != 13 || env.Weekday != "Sunday" {
+		t.Errorf("Should read the hour from the stored instant not %d %d %s", env.Hour, env.Day, env.Weekday)
+	}
+}
+
+func TestLoadExpressionRules(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "expressions.json")
+	os.WriteFile(filename, []byte(`{"timeout": "20ms", "rules": [{"name": "big_basket", "expression": "len(items) >= 5 ? 15 : 0"}]}`), 0o644)
+	expressions, err := LoadExpressionRules(filename)
+	if err != nil || expressions.timeout.String() != "20ms" {
+		t.Fatalf("Should load the expressions ... %v", err)
+	}
+	os.WriteFile(filename, []byte(`{"rules": [{"name": "broken", "expression": "len(items) >="}]}`), 0o644)
+	_, err2 := LoadExpressionRules(filename)
+	if err2 == nil || !strings.Contains(err2.Error(), "expression rule broken") {
+		t.Errorf("Should reject expressions that do not compile ... %v", err2)
+	}
+}
@@ -0,0 +1,522 @@
+// Package receipt holds the receipt and item types, their validation and the
+// rules used to award points for them.
+package receipt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var rxQuantity = regexp.MustCompile(`^\d+(\.\d{1,3})?$`)
+var rxDate = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+var rxTime = regexp.MustCompile(`^(\d{2}):(\d{2})$`)
+var twoPM, _ = time.Parse("15:04", "14:00")
+var fourPM, _ = time.Parse("15:04", "16:00")
+
+type Item struct {
+	ShortDescription string `json:"shortDescription" xml:"shortDescription"`
+	Price            string `json:"price" xml:"price"`
+	Quantity         string `json:"quantity,omitempty" xml:"quantity,omitempty"`
+	UnitPrice        string `json:"unitPrice,omitempty" xml:"unitPrice,omitempty"`
+	scored           *scoring
+	receipt          *Receipt
+}
+
+type Discount struct {
+	Description string `json:"description" xml:"description"`
+	Amount      string `json:"amount" xml:"amount"`
+	Item        *int   `json:"item,omitempty" xml:"item,omitempty"`
+}
+
+type Receipt struct {
+	Retailer     string     `json:"retailer" xml:"retailer"`
+	RetailerID   string     `json:"retailerId,omitempty" xml:"retailerId,omitempty"`
+	PurchaseDate string     `json:"purchaseDate" xml:"purchaseDate"`
+	PurchaseTime string     `json:"purchaseTime" xml:"purchaseTime"`
+	Items        []Item     `json:"items" xml:"items>item"`
+	Discounts    []Discount `json:"discounts,omitempty" xml:"discounts>discount,omitempty"`
+	Subtotal     string     `json:"subtotal,omitempty" xml:"subtotal,omitempty"`
+	Tax          string     `json:"tax,omitempty" xml:"tax,omitempty"`
+	Tip          string     `json:"tip,omitempty" xml:"tip,omitempty"`
+	Total        string     `json:"total" xml:"total"`
+	Currency     string     `json:"currency,omitempty" xml:"currency,omitempty"`
+	TimeZone     string     `json:"timeZone,omitempty" xml:"timeZone,omitempty"`
+	PurchasedAt  string     `json:"purchasedAt,omitempty" xml:"purchasedAt,omitempty"`
+	UserID       string     `json:"userId,omitempty" xml:"userId,omitempty"`
+	Cap          *PointsCap `json:"cap,omitempty" xml:"cap,omitempty"`
+	// Campaigns are the campaigns that applied when the receipt was stored.
+	Campaigns []Campaign `json:"-" xml:"-"`
+	scored    *scoring
+}
+
+type scoring struct {
+	currency   Currency
+	thresholds Thresholds
+}
+
+const (
+	AmountTotal    = "total"
+	AmountSubtotal = "subtotal"
+	AmountPreTip   = "pretip"
+)
+
+var amountNames = map[string]string{AmountTotal: "total", AmountSubtotal: "subtotal", AmountPreTip: "total before tip"}
+
+type ValidationOptions struct {
+	ToleranceCents int
+	TimeZones      TimeZones
+	Punctuation    string
+}
+
+type ValidationProblem struct {
+	Item     *int   `json:"item,omitempty"`
+	Discount *int   `json:"discount,omitempty"`
+	Field    string `json:"field"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+}
+
+type ValidationError struct {
+	Problems  []ValidationProblem
+	separator string
+}
+
+func (validationError *ValidationError) add(field string, reason string, message string) {
+	validationError.Problems = append(validationError.Problems, ValidationProblem{
+		Field:   field,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (validationError *ValidationError) addItem(index int, itemError *ValidationError) {
+	for _, problem := range itemError.Problems {
+		problem.Item = &index
+		validationError.Problems = append(validationError.Problems, problem)
+	}
+}
+
+func (validationError *ValidationError) addDiscount(index int, discountError *ValidationError) {
+	for _, problem := range discountError.Problems {
+		problem.Discount = &index
+		problem.Message = fmt.Sprintf("discount %d %s", index, problem.Message)
+		validationError.Problems = append(validationError.Problems, problem)
+	}
+}
+
+func (validationError *ValidationError) Error() string {
+	var messages []string
+	for i, problem := range validationError.Problems {
+		if problem.Item == nil {
+			messages = append(messages, problem.Message)
+		} else if i > 0 && validationError.Problems[i-1].Item != nil && *validationError.Problems[i-1].Item == *problem.Item {
+			messages[len(messages)-1] += ", " + problem.Message
+		} else {
+			messages = append(messages, fmt.Sprintf("item %d errors ... %s", *problem.Item, problem.Message))
+		}
+	}
+	return strings.Join(messages, validationError.separator)
+}
+
+func (item *Item) Validate() error {
+	errors := item.validate(Currencies[DefaultCurrency], "")
+	if len(errors.Problems) > 0 {
+		return errors
+	}
+	return nil
+}
+
+func (item *Item) validate(currency Currency, punctuation string) *ValidationError {
+	errors := &ValidationError{separator: ", "}
+
+	if strings.TrimSpace(item.ShortDescription) == "" {
+		errors.add("shortDescription", "empty", "shortDescription cannot be empty")
+	} else if !validText(item.ShortDescription, descriptionPunctuation+punctuation) {
+		errors.add("shortDescription", "invalid_format", fmt.Sprintf("invalid format for shortDescription (%s)", item.ShortDescription))
+	}
+
+	if strings.TrimSpace(item.Price) == "" {
+		errors.add("price", "empty", "price cannot be empty")
+	} else if !currency.ValidAmount(item.Price) {
+		errors.add("price", "invalid_format", fmt.Sprintf("invalid format for price (%s)", item.Price))
+	}
+
+	if item.Quantity != "" && !rxQuantity.MatchString(item.Quantity) {
+		errors.add("quantity", "invalid_format", fmt.Sprintf("invalid format for quantity (%s)", item.Quantity))
+	} else if item.Quantity != "" && item.quantityThousandths() == 0 {
+		errors.add("quantity", "invalid_format", "quantity must be greater than zero")
+	}
+	if item.UnitPrice != "" && !currency.validUnitPrice(item.UnitPrice) {
+		errors.add("unitPrice", "invalid_format", fmt.Sprintf("invalid format for unitPrice (%s)", item.UnitPrice))
+	}
+
+	if len(errors.Problems) == 0 && item.UnitPrice != "" {
+		lineTotal := currency.Format(item.lineTotal(currency))
+		if lineTotal != item.Price {
+			quantity := item.Quantity
+			if quantity == "" {
+				quantity = "1"
+			}
+			errors.add("price", "line_mismatch", fmt.Sprintf("quantity (%s) x unitPrice (%s) = %s != price (%s)", quantity, item.UnitPrice, lineTotal, item.Price))
+		}
+	}
+
+	return errors
+}
+
+func (item *Item) quantityThousandths() int {
+	if item.Quantity == "" {
+		return 1000
+	}
+	whole, fraction, _ := strings.Cut(item.Quantity, ".")
+	wholeInt, _ := strconv.Atoi(whole)
+	fractionInt, _ := strconv.Atoi((fraction + "000")[:3])
+	return wholeInt*1000 + fractionInt
+}
+
+func (item *Item) lineTotal(currency Currency) int {
+	whole, fraction, _ := strings.Cut(item.UnitPrice, ".")
+	wholeInt, _ := strconv.Atoi(whole)
+	fractionInt, _ := strconv.Atoi((fraction + "0")[:currency.MinorUnits+1])
+	unitPrice := wholeInt*int(currency.scale())*10 + fractionInt
+	return (item.quantityThousandths()*unitPrice + 5000) / 10000
+}
+
+func (item *Item) Units() int {
+	return item.quantityThousandths() / 1000
+}
+
+func (discount *Discount) validate(items int, currency Currency, punctuation string) *ValidationError {
+	errors := &ValidationError{separator: ", "}
+	if strings.TrimSpace(discount.Description) == "" {
+		errors.add("description", "empty", "description cannot be empty")
+	} else if !validText(discount.Description, descriptionPunctuation+punctuation) {
+		errors.add("description", "invalid_format", fmt.Sprintf("invalid format for description (%s)", discount.Description))
+	}
+	if strings.TrimSpace(discount.Amount) == "" {
+		errors.add("amount", "empty", "amount cannot be empty")
+	} else if !currency.ValidAmount(discount.Amount) {
+		errors.add("amount", "invalid_format", fmt.Sprintf("invalid format for amount (%s)", discount.Amount))
+	}
+	if discount.Item != nil && (*discount.Item < 0 || *discount.Item >= items) {
+		errors.add("item", "invalid_format", fmt.Sprintf("item %d is not on the receipt", *discount.Item))
+	}
+	return errors
+}
+
+func mismatched(currency Currency, calculated int, given string, tolerance int) bool {
+	if currency.Format(calculated) == given {
+		return false
+	}
+	if !currency.ValidAmount(given) {
+		return true
+	}
+	difference := calculated - currency.Minor(given)
+	return difference > tolerance || -difference > tolerance
+}
+
+func (item *Item) scoring() scoring {
+	if item.scored != nil {
+		return *item.scored
+	}
+	currency := Currencies[DefaultCurrency]
+	return scoring{currency: currency, thresholds: currency.DefaultThresholds()}
+}
+
+func (receipt *Receipt) scoring() scoring {
+	if receipt.scored != nil {
+		return *receipt.scored
+	}
+	currency := receipt.currency()
+	return scoring{currency: currency, thresholds: currency.DefaultThresholds()}
+}
+
+func (item *Item) PointsForItem() (int, string) {
+	points := 0
+	trimmedDescription := strings.TrimSpace(item.ShortDescription)
+	if characterCount(trimmedDescription)%3 == 0 {
+		scored := item.scoring()
+		itemPriceFloat := float64(scored.currency.Minor(item.Price)) / float64(scored.currency.Minor(scored.thresholds.PriceUnit))
+		points = int(math.Ceil(itemPriceFloat * 0.2))
+	}
+	message := fmt.Sprintf("%d point(s) for item (%s | %s)", points, item.ShortDescription, item.Price)
+	return points, message
+}
+
+func (item *Item) PointsForItemTitle() (int, string) {
+	points := 0
+	trimmedDescription := strings.TrimSpace(item.ShortDescription)
+	if trimmedDescription[0] == 'g' || trimmedDescription[0] == 'G' {
+		points = 10
+	}
+	message := fmt.Sprintf("%d point(s) for item title (%s | %s)", points, item.ShortDescription, item.Price)
+	return points, message
+}
+
+func (receipt *Receipt) Validate() error {
+	return receipt.ValidateWith(ValidationOptions{})
+}
+
+func (receipt *Receipt) ValidateWith(options ValidationOptions) error {
+	errors := &ValidationError{separator: " | "}
+
+	if strings.TrimSpace(receipt.Retailer) == "" {
+		errors.add("retailer", "empty", "retailer cannot be empty")
+	} else if !validText(receipt.Retailer, retailerPunctuation+options.Punctuation) {
+		errors.add("retailer", "invalid_format", fmt.Sprintf("invalid format for retailer (%s)", receipt.Retailer))
+	}
+
+	if strings.TrimSpace(receipt.PurchaseDate) == "" {
+		errors.add("purchaseDate", "empty", "purchaseDate cannot be empty")
+	} else if !rxDate.MatchString(receipt.PurchaseDate) {
+		errors.add("purchaseDate", "invalid_format", fmt.Sprintf("invalid format for purchaseDate (%s)", receipt.PurchaseDate))
+	} else {
+		_, err := time.Parse("2006-01-02", receipt.PurchaseDate)
+		if err != nil {
+			errors.add("purchaseDate", "unparseable", fmt.Sprintf("purchaseDate cannot be parsed (%s)", receipt.PurchaseDate))
+		}
+	}
+
+	if strings.TrimSpace(receipt.PurchaseTime) == "" {
+		errors.add("purchaseTime", "empty", "purchaseTime cannot be empty")
+	} else if !rxTime.MatchString(receipt.PurchaseTime) {
+		errors.add("purchaseTime", "invalid_format", fmt.Sprintf("invalid format for purchaseTime (%s)", receipt.PurchaseTime))
+	} else {
+		_, err := time.Parse("15:04", receipt.PurchaseTime)
+		if err != nil {
+			errors.add("purchaseTime", "unparseable", fmt.Sprintf("purchaseTime cannot be parsed (%s)", receipt.PurchaseTime))
+		}
+	}
+
+	if receipt.TimeZone != "" {
+		if _, err := time.LoadLocation(receipt.TimeZone); err != nil {
+			errors.add("timeZone", "invalid_time_zone", fmt.Sprintf("unknown time zone (%s)", receipt.TimeZone))
+		}
+	}
+
+	if strings.TrimSpace(receipt.Total) == "" {
+		errors.add("total", "empty", "total cannot be empty")
+	}
+
+	currency, known := LookupCurrency(receipt.Currency)
+	if !known {
+		errors.add("currency", "invalid_currency", fmt.Sprintf("unknown currency (%s)", receipt.Currency))
+		currency = Currencies[DefaultCurrency]
+	}
+	if strings.TrimSpace(receipt.Total) != "" && !currency.ValidAmount(receipt.Total) {
+		errors.add("total", "invalid_format", fmt.Sprintf("invalid format for total (%s)", receipt.Total))
+	}
+
+	calculatedTotal := 0
+	for index, item := range receipt.Items {
+		calculatedTotal += currency.Minor(item.Price)
+		itemErrors := item.validate(currency, options.Punctuation)
+		if len(itemErrors.Problems) > 0 {
+			errors.addItem(index, itemErrors)
+		}
+	}
+
+	discounts := 0
+	for index, discount := range receipt.Discounts {
+		discounts += currency.Minor(discount.Amount)
+		discountErrors := discount.validate(len(receipt.Items), currency, options.Punctuation)
+		if len(discountErrors.Problems) > 0 {
+			errors.addDiscount(index, discountErrors)
+		}
+	}
+
+	for _, amount := range []struct{ field, value string }{{"subtotal", receipt.Subtotal}, {"tax", receipt.Tax}, {"tip", receipt.Tip}} {
+		if amount.value != "" && !currency.ValidAmount(amount.value) {
+			errors.add(amount.field, "invalid_format", fmt.Sprintf("invalid format for %s (%s)", amount.field, amount.value))
+		}
+	}
+
+	itemsDescription := fmt.Sprintf("sum of item prices (%s)", currency.Format(calculatedTotal))
+	if len(receipt.Discounts) > 0 {
+		itemsDescription = fmt.Sprintf("sum of item prices (%s) minus discounts (%s)", currency.Format(calculatedTotal), currency.Format(discounts))
+	}
+	calculatedTotal -= discounts
+
+	if receipt.Subtotal == "" && receipt.Tax == "" && receipt.Tip == "" {
+		if mismatched(currency, calculatedTotal, receipt.Total, options.ToleranceCents) {
+			errors.add("total", "total_mismatch", fmt.Sprintf("%s != given total (%s)", itemsDescription, receipt.Total))
+		}
+	} else {
+		parts := []string{itemsDescription}
+		if receipt.Subtotal != "" {
+			if mismatched(currency, calculatedTotal, receipt.Subtotal, options.ToleranceCents) {
+				errors.add("subtotal", "subtotal_mismatch", fmt.Sprintf("%s != subtotal (%s)", itemsDescription, receipt.Subtotal))
+			}
+			calculatedTotal = currency.Minor(receipt.Subtotal)
+			parts = []string{fmt.Sprintf("subtotal (%s)", receipt.Subtotal)}
+		}
+		if receipt.Tax != "" {
+			calculatedTotal += currency.Minor(receipt.Tax)
+			parts = append(parts, fmt.Sprintf("tax (%s)", receipt.Tax))
+		}
+		if receipt.Tip != "" {
+			calculatedTotal += currency.Minor(receipt.Tip)
+			parts = append(parts, fmt.Sprintf("tip (%s)", receipt.Tip))
+		}
+		if mismatched(currency, calculatedTotal, receipt.Total, options.ToleranceCents) {
+			errors.add("total", "total_mismatch", fmt.Sprintf("%s = %s != given total (%s)", strings.Join(parts, " + "), currency.Format(calculatedTotal), receipt.Total))
+		}
+	}
+
+	if len(errors.Problems) > 0 {
+		return errors
+	}
+	return nil
+}
+
+func (receipt *Receipt) PointsForRetailerName() (int, string) {
+	points := 0
+	for _, char := range norm.NFC.String(receipt.Retailer) {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			points += 1
+		}
+	}
+	message := fmt.Sprintf("%d points for retailer name (%s)", points, receipt.Retailer)
+	return points, message
+}
+
+func (receipt *Receipt) Amount(name string) string {
+	currency := receipt.currency()
+	switch name {
+	case AmountSubtotal:
+		if receipt.Subtotal != "" {
+			return receipt.Subtotal
+		}
+		subtotal := 0
+		for _, item := range receipt.Items {
+			subtotal += currency.Minor(item.Price)
+		}
+		for _, discount := range receipt.Discounts {
+			subtotal -= currency.Minor(discount.Amount)
+		}
+		return currency.Format(subtotal)
+	case AmountPreTip:
+		if receipt.Tip != "" {
+			return currency.Format(currency.Minor(receipt.Total) - currency.Minor(receipt.Tip))
+		}
+	}
+	return receipt.Total
+}
+
+func (receipt *Receipt) PointsForRoundDollarAmount() (int, string) {
+	return receipt.PointsForRoundDollar(AmountTotal)
+}
+
+func (receipt *Receipt) PointsForRoundDollar(amountName string) (int, string) {
+	scored := receipt.scoring()
+	amount := receipt.Amount(amountName)
+	points := 0
+	if scored.currency.Minor(amount)%scored.currency.Minor(scored.thresholds.RoundAmount) == 0 {
+		points = 50
+	}
+	unit := "dollar "
+	if scored.currency.Code != DefaultCurrency {
+		unit = ""
+	}
+	name := "amount"
+	if amountName != AmountTotal {
+		name = amountNames[amountName]
+	}
+	return points, fmt.Sprintf("%d points for round %s%s (%s)", points, unit, name, scored.currency.describe(amount))
+}
+
+func (receipt *Receipt) PointsForCentsMultiple25() (int, string) {
+	return receipt.PointsForCentsMultiple(AmountTotal)
+}
+
+func (receipt *Receipt) PointsForCentsMultiple(amountName string) (int, string) {
+	scored := receipt.scoring()
+	amount := receipt.Amount(amountName)
+	points := 0
+	if scored.currency.Minor(amount)%scored.currency.Minor(scored.thresholds.Multiple) == 0 {
+		points = 25
+	}
+	name := ""
+	if amountName != AmountTotal {
+		name = amountNames[amountName] + " "
+	}
+	return points, fmt.Sprintf("%d points for %sbeing multiple of %s (%s)", points, name, scored.thresholds.Multiple, scored.currency.describe(amount))
+}
+
+func (receipt *Receipt) PointsForNumItems() (int, string) {
+	pairsOfItems := int(math.Floor(float64(len(receipt.Items)) / 2))
+	points := pairsOfItems * 5
+	message := fmt.Sprintf("%d points for number of items (%d)", points, len(receipt.Items))
+	return points, message
+}
+
+func (receipt *Receipt) PointsForNumUnits() (int, string) {
+	units := 0
+	for _, item := range receipt.Items {
+		units += max(item.Units(), 1)
+	}
+	points := (units / 2) * 5
+	message := fmt.Sprintf("%d points for number of units (%d)", points, units)
+	return points, message
+}
+
+func (receipt *Receipt) PointsForPurchaseDate() (int, string) {
+	points := 0
+	match := rxDate.FindStringSubmatch(receipt.PurchaseDate)
+	dayInt, _ := strconv.Atoi(match[3])
+	if local, located := receipt.localPurchaseTime(); located {
+		dayInt = local.Day()
+	}
+	if !(dayInt%2 == 0) {
+		points = 6
+	}
+	message := fmt.Sprintf("%d points for purchase day being odd (%s)", points, receipt.PurchaseDate)
+	return points, message
+}
+
+func (receipt *Receipt) PointsForPurchaseTime() (int, string) {
+	points := 0
+	timeObj, _ := time.Parse("15:04", receipt.PurchaseTime)
+	shown := receipt.PurchaseTime
+	if local, located := receipt.localPurchaseTime(); located {
+		timeObj = time.Date(0, 1, 1, local.Hour(), local.Minute(), 0, 0, time.UTC)
+		if local.Format("15:04") != receipt.PurchaseTime {
+			shown = local.Format("15:04 -07:00")
+		}
+	}
+	if timeObj.After(twoPM) && timeObj.Before(fourPM) {
+		points = 10
+	}
+	message := fmt.Sprintf("%d points for time of purchase between 2pm and 4pm (%s)", points, shown)
+	return points, message
+}
+
+func LoadJSON(filename string, v interface{}) error {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("Error opening file: %w", err)
+	}
+	defer fp.Close()
+
+	data, err2 := ioutil.ReadAll(fp)
+	if err != nil {
+		return fmt.Errorf("Error reading file: %w", err2)
+	}
+
+	err3 := json.Unmarshal(data, v)
+	if err3 != nil {
+		return fmt.Errorf("Error unmarshaling JSON: %w", err3)
+	}
+
+	return nil
+}
@@ -0,0 +1,921 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var receiptExample1, receiptExample2, receiptExample3 Receipt
+
+var err1 = LoadJSON("../example1.json", &receiptExample1)
+var err2 = LoadJSON("../example2.json", &receiptExample2)
+var err3 = LoadJSON("../example3.json", &receiptExample3)
+
+func TestItemValidate1(t *testing.T) {
+	item := Item{
+		ShortDescription: "Skittles",
+		Price:            "1.50",
+	}
+	err := item.Validate()
+	if err != nil {
+		t.Errorf("Validation errors for item %v", item)
+	}
+}
+
+func TestItemValidateBadPrice(t *testing.T) {
+	item := Item{
+		ShortDescription: "Skittles",
+		Price:            "1.50.2",
+	}
+	err := item.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for item %v", item)
+	}
+	if !strings.Contains(err.Error(), "invalid format for price") {
+		t.Errorf("Validation error should contain 'invalid format for price' ... %s", err.Error())
+	}
+}
+
+func TestItemValidateEmptyPrice(t *testing.T) {
+	item := Item{
+		ShortDescription: "Skittles",
+		Price:            "",
+	}
+	err := item.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for item %v", item)
+	}
+	if !strings.Contains(err.Error(), "price cannot be empty") {
+		t.Errorf("Validation error should contain 'price cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestItemValidateBadDescription(t *testing.T) {
+	item := Item{
+		ShortDescription: "Skittles #5",
+		Price:            "1.50",
+	}
+	err := item.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for item %v", item)
+	}
+	if !strings.Contains(err.Error(), "invalid format for shortDescription") {
+		t.Errorf("Validation error should contain 'invalid format for shortDescription' ... %s", err.Error())
+	}
+}
+
+func TestItemValidateEmptyDescription(t *testing.T) {
+	item := Item{
+		ShortDescription: "",
+		Price:            "1.50",
+	}
+	err := item.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for item %v", item)
+	}
+	if !strings.Contains(err.Error(), "shortDescription cannot be empty") {
+		t.Errorf("Validation error should contain 'shortDescription cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestItemPoints1(t *testing.T) {
+	item := Item{
+		ShortDescription: "Skittles",
+		Price:            "1.50",
+	}
+	points, message := item.PointsForItem()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestItemPoints2(t *testing.T) {
+	item := Item{
+		ShortDescription: "Dasani",
+		Price:            "1.40",
+	}
+	points, message := item.PointsForItem()
+	if points != 1 {
+		t.Errorf("Should have 1 point ... %s", message)
+	}
+}
+
+func TestItemPoints3(t *testing.T) {
+	item := Item{
+		ShortDescription: "Emils Cheese Pizza",
+		Price:            "12.25",
+	}
+	points, message := item.PointsForItem()
+	if points != 3 {
+		t.Errorf("Should have 3 points ... %s", message)
+	}
+}
+
+func TestReceiptValidate1(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err != nil {
+		t.Errorf("Validation errors for item %v", receipt)
+	}
+}
+
+func TestReceiptValidateBadRetailer(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store!!",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "invalid format for retailer") {
+		t.Errorf("Validation error should contain 'invalid format for retailer' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateEmptyRetailer(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "retailer cannot be empty") {
+		t.Errorf("Validation error should contain 'retailer cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestReceiptRetailerNamePoints1(t *testing.T) {
+	receipt := Receipt{
+		Retailer: "Corner Store",
+	}
+	points, message := receipt.PointsForRetailerName()
+	if points != 11 {
+		t.Errorf("Should have 11 points ... %s", message)
+	}
+}
+
+func TestReceiptRetailerNamePoints2(t *testing.T) {
+	receipt := Receipt{
+		Retailer: "Walgreens",
+	}
+	points, message := receipt.PointsForRetailerName()
+	if points != 9 {
+		t.Errorf("Should have 9 points ... %s", message)
+	}
+}
+
+func TestReceiptValidateBadPurchaseDate1(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-110",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "invalid format for purchaseDate") {
+		t.Errorf("Validation error should contain 'invalid format for purchaseDate' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateBadPurchaseDate2(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-22-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "purchaseDate cannot be parsed") {
+		t.Errorf("Validation error should contain 'purchaseDate cannot be parsed' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateEmptyPurchaseDate(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "purchaseDate cannot be empty") {
+		t.Errorf("Validation error should contain 'purchaseDate cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestReceiptPurchaseDatePoints1(t *testing.T) {
+	receipt := Receipt{
+		PurchaseDate: "2024-12-11",
+	}
+	points, message := receipt.PointsForPurchaseDate()
+	if points != 6 {
+		t.Errorf("Should have 6 points ... %s", message)
+	}
+}
+
+func TestReceiptPurchaseDatePoints2(t *testing.T) {
+	receipt := Receipt{
+		PurchaseDate: "2024-12-12",
+	}
+	points, message := receipt.PointsForPurchaseDate()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptValidateBadPurchaseTime1(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:051",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "invalid format for purchaseTime") {
+		t.Errorf("Validation error should contain 'invalid format for purchaseTime' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateBadPurchaseTime2(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "25:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "purchaseTime cannot be parsed") {
+		t.Errorf("Validation error should contain 'purchaseTime cannot be parsed' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateEmptyPurchaseTime(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "purchaseTime cannot be empty") {
+		t.Errorf("Validation error should contain 'purchaseTime cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestReceiptPurchaseTimePoints1(t *testing.T) {
+	receipt := Receipt{
+		PurchaseTime: "15:05",
+	}
+	points, message := receipt.PointsForPurchaseTime()
+	if points != 10 {
+		t.Errorf("Should have 10 points ... %s", message)
+	}
+}
+
+func TestReceiptPurchaseTimePoints2(t *testing.T) {
+	receipt := Receipt{
+		PurchaseTime: "11:05",
+	}
+	points, message := receipt.PointsForPurchaseTime()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptPurchaseTimePoints3(t *testing.T) {
+	receipt := Receipt{
+		PurchaseTime: "17:05",
+	}
+	points, message := receipt.PointsForPurchaseTime()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptValidateBadTotal1(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.00a",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "invalid format for total") {
+		t.Errorf("Validation error should contain 'invalid format for total' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateBadTotal2(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "3.05",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "sum of item prices") {
+		t.Errorf("Validation error should contain 'sum of item prices' ... %s", err.Error())
+	}
+}
+
+func TestReceiptValidateEmptyTotal(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+		Total: "",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	if !strings.Contains(err.Error(), "total cannot be empty") {
+		t.Errorf("Validation error should contain 'total cannot be empty' ... %s", err.Error())
+	}
+}
+
+func TestReceiptRoundDollarPoints1(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.00",
+	}
+	points, message := receipt.PointsForRoundDollarAmount()
+	if points != 50 {
+		t.Errorf("Should have 50 points ... %s", message)
+	}
+}
+
+func TestReceiptRoundDollarPoints2(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.50",
+	}
+	points, message := receipt.PointsForRoundDollarAmount()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptCentsMultiple25Points1(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.00",
+	}
+	points, message := receipt.PointsForCentsMultiple25()
+	if points != 25 {
+		t.Errorf("Should have 25 points ... %s", message)
+	}
+}
+
+func TestReceiptCentsMultiple25Points2(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.25",
+	}
+	points, message := receipt.PointsForCentsMultiple25()
+	if points != 25 {
+		t.Errorf("Should have 25 points ... %s", message)
+	}
+}
+
+func TestReceiptCentsMultiple25Points3(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.50",
+	}
+	points, message := receipt.PointsForCentsMultiple25()
+	if points != 25 {
+		t.Errorf("Should have 25 points ... %s", message)
+	}
+}
+
+func TestReceiptCentsMultiple25Points4(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.75",
+	}
+	points, message := receipt.PointsForCentsMultiple25()
+	if points != 25 {
+		t.Errorf("Should have 25 points ... %s", message)
+	}
+}
+
+func TestReceiptCentsMultiple25Points5(t *testing.T) {
+	receipt := Receipt{
+		Total: "3.85",
+	}
+	points, message := receipt.PointsForCentsMultiple25()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptNumItemsPoints1(t *testing.T) {
+	receipt := Receipt{
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+	}
+	points, message := receipt.PointsForNumItems()
+	if points != 0 {
+		t.Errorf("Should have 0 points ... %s", message)
+	}
+}
+
+func TestReceiptNumItemsPoints2(t *testing.T) {
+	receipt := Receipt{
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+	}
+	points, message := receipt.PointsForNumItems()
+	if points != 5 {
+		t.Errorf("Should have 5 points ... %s", message)
+	}
+}
+
+func TestReceiptNumItemsPoints3(t *testing.T) {
+	receipt := Receipt{
+		Items: []Item{
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+			{
+				ShortDescription: "Skittles",
+				Price:            "1.50",
+			},
+		},
+	}
+	points, message := receipt.PointsForNumItems()
+	if points != 10 {
+		t.Errorf("Should have 10 points ... %s", message)
+	}
+}
+
+func TestJSONExample1(t *testing.T) {
+	err := receiptExample1.Validate()
+	if err != nil {
+		t.Errorf("Validation errors for receipt %v", receiptExample1)
+	}
+	totalPoints, breakdown := receiptExample1.GetTotalPointsAndBreakdown()
+	if totalPoints != 15 {
+		t.Errorf("Should have 15 points ... %v", breakdown)
+	}
+	lenBreakdown := len(breakdown)
+	if lenBreakdown != 10 {
+		t.Errorf("Should have 10 items in the breakdown not %d ... %v", lenBreakdown, breakdown)
+	}
+}
+
+func TestJSONExample2(t *testing.T) {
+	err := receiptExample2.Validate()
+	if err != nil {
+		t.Errorf("Validation errors for receipt %v", receiptExample2)
+	}
+	totalPoints, breakdown := receiptExample2.GetTotalPointsAndBreakdown()
+	if totalPoints != 28 {
+		t.Errorf("Should have 28 points ... %v", breakdown)
+	}
+	lenBreakdown := len(breakdown)
+	if lenBreakdown != 16 {
+		t.Errorf("Should have 16 items in the breakdown not %d ... %v", lenBreakdown, breakdown)
+	}
+}
+
+func TestJSONExample3(t *testing.T) {
+	err := receiptExample3.Validate()
+	if err != nil {
+		t.Errorf("Validation errors for receipt %v", receiptExample3)
+	}
+	totalPoints, breakdown := receiptExample3.GetTotalPointsAndBreakdown()
+	if totalPoints != 149 {
+		t.Errorf("Should have 149 points not %d ... %v", totalPoints, breakdown)
+	}
+	lenBreakdown := len(breakdown)
+	if lenBreakdown != 14 {
+		t.Errorf("Should have 14 items in the breakdown not %d ... %v", lenBreakdown, breakdown)
+	}
+}
+
+func TestReceiptValidateItemErrorsGrouped(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{
+				ShortDescription: "Skittles #5",
+				Price:            "1.5",
+			},
+		},
+		Total: "1.50",
+	}
+	err := receipt.Validate()
+	if err == nil {
+		t.Errorf("Should have a validation error for receipt %v", receipt)
+	}
+	expected := "item 0 errors ... invalid format for shortDescription (Skittles #5), invalid format for price (1.5)"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("Validation error should contain '%s' ... %s", expected, err.Error())
+	}
+	validationError := err.(*ValidationError)
+	if len(validationError.Problems) != 2 {
+		t.Errorf("Should have 2 validation problems not %d ... %v", len(validationError.Problems), validationError.Problems)
+	}
+}
+
+func TestItemValidateQuantity(t *testing.T) {
+	valid := []Item{
+		{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: "0.75"},
+		{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3"},
+		{ShortDescription: "Bananas", Price: "1.09", Quantity: "1.82", UnitPrice: "0.599"},
+		{ShortDescription: "Gatorade", Price: "0.75", UnitPrice: "0.75"},
+	}
+	for _, item := range valid {
+		if err := item.Validate(); err != nil {
+			t.Errorf("Should be valid %+v ... %s", item, err)
+		}
+	}
+	invalid := map[string]Item{
+		"invalid format for quantity (three)":                    {ShortDescription: "Gatorade", Price: "2.25", Quantity: "three"},
+		"quantity must be greater than zero":                     {ShortDescription: "Gatorade", Price: "0.00", Quantity: "0"},
+		"invalid format for unitPrice (.75)":                     {ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: ".75"},
+		"quantity (3) x unitPrice (0.75) = 2.25 != price (2.50)": {ShortDescription: "Gatorade", Price: "2.50", Quantity: "3", UnitPrice: "0.75"},
+		"quantity (1) x unitPrice (0.75) = 0.75 != price (2.25)": {ShortDescription: "Gatorade", Price: "2.25", UnitPrice: "0.75"},
+	}
+	for message, item := range invalid {
+		err := item.Validate()
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Should have validation error %q for %+v ... %v", message, item, err)
+		}
+	}
+}
+
+func TestReceiptValidateDiscounts(t *testing.T) {
+	coupon := 0
+	receipt := Receipt{
+		Retailer:     "Corner Store",
+		PurchaseDate: "2024-12-11",
+		PurchaseTime: "15:05",
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: "0.75"},
+			{ShortDescription: "Skittles", Price: "1.50"},
+		},
+		Discounts: []Discount{{Description: "Gatorade coupon", Amount: "0.50", Item: &coupon}},
+		Total:     "3.25",
+	}
+	err := receipt.Validate()
+	if err != nil {
+		t.Errorf("Should be valid ... %s", err)
+	}
+
+	receipt.Total = "3.75"
+	err2 := receipt.Validate()
+	if err2 == nil || !strings.Contains(err2.Error(), "sum of item prices (3.75) minus discounts (0.50) != given total (3.75)") {
+		t.Errorf("Should have a total mismatch ... %v", err2)
+	}
+
+	missing := 5
+	receipt.Total = "3.25"
+	receipt.Discounts[0] = Discount{Description: "", Amount: "half", Item: &missing}
+	err3 := receipt.Validate()
+	problems := err3.(*ValidationError).Problems
+	fields := []string{}
+	for _, problem := range problems {
+		if problem.Discount != nil {
+			fields = append(fields, fmt.Sprintf("discount %d %s", *problem.Discount, problem.Field))
+		} else {
+			fields = append(fields, problem.Field)
+		}
+	}
+	if strings.Join(fields, ",") != "discount 0 description,discount 0 amount,discount 0 item,total" {
+		t.Errorf("Should have discount problems not %v ... %s", fields, err3)
+	}
+}
+
+func TestReceiptNumUnitsPoints(t *testing.T) {
+	receipt := Receipt{
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3"},
+			{ShortDescription: "Bananas", Price: "1.09", Quantity: "0.82"},
+			{ShortDescription: "Skittles", Price: "1.50"},
+		},
+	}
+	points, message := receipt.PointsForNumUnits()
+	if points != 10 {
+		t.Errorf("Should have 10 points for 5 units ... %s", message)
+	}
+	itemPoints, _ := receipt.PointsForNumItems()
+	if itemPoints != 5 {
+		t.Errorf("Should still have 5 points for 3 items not %d", itemPoints)
+	}
+	if receipt.Items[1].Units() != 0 || receipt.Items[2].Units() != 1 {
+		t.Errorf("Should count whole units only")
+	}
+}
+
+func TestQuantityRuleset(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:13",
+		Items:        []Item{{ShortDescription: "Gatorade", Price: "2.25", Quantity: "3", UnitPrice: "0.75"}},
+		Total:        "2.25",
+	}
+	standard, _ := SummarizeRules(StandardRuleset.Evaluate(context.Background(), &receipt))
+	quantity, _ := SummarizeRules(Rulesets["quantity-1"].Evaluate(context.Background(), &receipt))
+	if quantity-standard != 5 {
+		t.Errorf("Should score a pair of units under quantity-1 (%d vs %d)", quantity, standard)
+	}
+}
+
+func TestReceiptValidateSubtotalTaxAndTip(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Cafe",
+		PurchaseDate: "2022-05-06",
+		PurchaseTime: "12:30",
+		Items: []Item{
+			{ShortDescription: "Sandwich", Price: "8.00"},
+			{ShortDescription: "Soup", Price: "4.00"},
+		},
+		Subtotal: "12.00",
+		Tax:      "0.96",
+		Tip:      "2.00",
+		Total:    "14.96",
+	}
+	err := receipt.Validate()
+	if err != nil {
+		t.Errorf("Should be valid ... %s", err)
+	}
+
+	receipt.Total = "14.97"
+	err2 := receipt.Validate()
+	if err2 == nil || !strings.Contains(err2.Error(), "subtotal (12.00) + tax (0.96) + tip (2.00) = 14.96 != given total (14.97)") {
+		t.Errorf("Should have a total mismatch ... %v", err2)
+	}
+	err3 := receipt.ValidateWith(ValidationOptions{ToleranceCents: 1})
+	if err3 != nil {
+		t.Errorf("Should allow a one cent rounding difference ... %s", err3)
+	}
+
+	receipt.Total = "14.96"
+	receipt.Subtotal = "11.00"
+	receipt.Tip = "3.00"
+	err4 := receipt.Validate()
+	problems := err4.(*ValidationError).Problems
+	if len(problems) != 1 || problems[0].Field != "subtotal" || problems[0].Reason != "subtotal_mismatch" {
+		t.Errorf("Should have a subtotal mismatch only ... %s", err4)
+	}
+
+	receipt.Subtotal = "12"
+	receipt.Tip = "2.00"
+	err5 := receipt.Validate()
+	if err5 == nil || !strings.Contains(err5.Error(), "invalid format for subtotal (12)") {
+		t.Errorf("Should have a subtotal format problem ... %v", err5)
+	}
+}
+
+func TestReceiptAmount(t *testing.T) {
+	receipt := Receipt{
+		Items:     []Item{{ShortDescription: "Sandwich", Price: "8.00"}, {ShortDescription: "Soup", Price: "4.00"}},
+		Discounts: []Discount{{Description: "Lunch deal", Amount: "1.00"}},
+		Tax:       "0.88",
+		Tip:       "2.25",
+		Total:     "14.13",
+	}
+	if receipt.Amount(AmountTotal) != "14.13" || receipt.Amount(AmountSubtotal) != "11.00" || receipt.Amount(AmountPreTip) != "11.88" {
+		t.Errorf("Should resolve amounts not %s %s %s", receipt.Amount(AmountTotal), receipt.Amount(AmountSubtotal), receipt.Amount(AmountPreTip))
+	}
+	points, message := receipt.PointsForRoundDollar(AmountSubtotal)
+	if points != 50 || message != "50 points for round dollar subtotal (11.00)" {
+		t.Errorf("Should score the subtotal ... %d %s", points, message)
+	}
+	points2, message2 := receipt.PointsForCentsMultiple(AmountTotal)
+	if points2 != 0 || message2 != "0 points for being multiple of 0.25 (14.13)" {
+		t.Errorf("Should keep the total message ... %d %s", points2, message2)
+	}
+}
+
+func TestRulesetWithAmount(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Corner Cafe",
+		PurchaseDate: "2022-05-06",
+		PurchaseTime: "12:30",
+		Items:        []Item{{ShortDescription: "Sandwich", Price: "8.00"}},
+		Tip:          "1.50",
+		Total:        "9.50",
+	}
+	same, err := StandardRuleset.WithAmount(AmountTotal)
+	if err != nil || same != StandardRuleset {
+		t.Errorf("Should keep the standard ruleset for the total ... %v", err)
+	}
+	pretip, err2 := StandardRuleset.WithAmount(AmountPreTip)
+	if err2 != nil || pretip.Version != "standard-1+pretip" {
+		t.Fatalf("Should derive a pretip ruleset ... %v", err2)
+	}
+	standard, _ := SummarizeRules(StandardRuleset.Evaluate(context.Background(), &receipt))
+	derived, _ := SummarizeRules(pretip.Evaluate(context.Background(), &receipt))
+	if derived-standard != 50 {
+		t.Errorf("Should award round dollar points for the amount before tip (%d vs %d)", derived, standard)
+	}
+	_, err3 := StandardRuleset.WithAmount("change")
+	if err3 == nil {
+		t.Errorf("Should reject an unknown amount")
+	}
+}
+
+func TestRulesetWithout(t *testing.T) {
+	bonuses := &Bonuses{Rules: []BonusRule{{ID: "target", Retailer: "Target", Points: 5}}}
+	ruleset := StandardRuleset.WithBonuses(bonuses)
+	without, err := ruleset.Without("retailer_name", "item_title")
+	if err != nil || len(without.Rules) != len(StandardRuleset.Rules)-2 || len(without.BonusRules()) != 1 {
+		t.Fatalf("Should drop the two rules and keep the bonuses ... %v", err)
+	}
+	if len(StandardRuleset.Rules) != 8 {
+		t.Errorf("Should leave the original ruleset alone")
+	}
+	_, err2 := ruleset.Without("retailer")
+	if err2 == nil || err2.Error() != "standard-1 has no rule retailer" {
+		t.Errorf("Should reject an unknown rule ... %v", err2)
+	}
+
+	rebased := ruleset.Rebased(QuantityRuleset)
+	if rebased.Version != "quantity-1" || rebased.Rules[3].Name != "num_units" || len(rebased.BonusRules()) != 1 {
+		t.Errorf("Should take the rules of the base and keep the bonuses ... %+v", rebased)
+	}
+}
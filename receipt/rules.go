@@ -0,0 +1,266 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("receipt-processor/receipt")
+
+type RuleResult struct {
+	Rule    string
+	Item    *int
+	Points  int
+	Message string
+}
+
+// Rule scores a receipt with Receipt, or with ReceiptContext when it needs the
+// scoring request's context, or each of its items with Item.
+type Rule struct {
+	Name           string
+	Receipt        func(receipt *Receipt) (int, string)
+	ReceiptContext func(ctx context.Context, receipt *Receipt) (int, string)
+	Item           func(item *Item) (int, string)
+}
+
+type Ruleset struct {
+	Version    string
+	Rules      []Rule
+	currencies *CurrencyConfig
+	bonuses    *Bonuses
+	campaigns  []Campaign
+}
+
+var StandardRuleset = &Ruleset{
+	Version: "standard-1",
+	Rules: []Rule{
+		{Name: "retailer_name", Receipt: (*Receipt).PointsForRetailerName},
+		{Name: "round_dollar_amount", Receipt: (*Receipt).PointsForRoundDollarAmount},
+		{Name: "cents_multiple_25", Receipt: (*Receipt).PointsForCentsMultiple25},
+		{Name: "num_items", Receipt: (*Receipt).PointsForNumItems},
+		{Name: "item_description", Item: (*Item).PointsForItem},
+		{Name: "item_title", Item: (*Item).PointsForItemTitle},
+		{Name: "purchase_date", Receipt: (*Receipt).PointsForPurchaseDate},
+		{Name: "purchase_time", Receipt: (*Receipt).PointsForPurchaseTime},
+	},
+}
+
+var QuantityRuleset = &Ruleset{
+	Version: "quantity-1",
+	Rules: []Rule{
+		{Name: "retailer_name", Receipt: (*Receipt).PointsForRetailerName},
+		{Name: "round_dollar_amount", Receipt: (*Receipt).PointsForRoundDollarAmount},
+		{Name: "cents_multiple_25", Receipt: (*Receipt).PointsForCentsMultiple25},
+		{Name: "num_units", Receipt: (*Receipt).PointsForNumUnits},
+		{Name: "item_description", Item: (*Item).PointsForItem},
+		{Name: "item_title", Item: (*Item).PointsForItemTitle},
+		{Name: "purchase_date", Receipt: (*Receipt).PointsForPurchaseDate},
+		{Name: "purchase_time", Receipt: (*Receipt).PointsForPurchaseTime},
+	},
+}
+
+var Rulesets = map[string]*Ruleset{
+	StandardRuleset.Version: StandardRuleset,
+	QuantityRuleset.Version: QuantityRuleset,
+}
+
+var amountRules = map[string]func(amount string) func(receipt *Receipt) (int, string){
+	"round_dollar_amount": func(amount string) func(receipt *Receipt) (int, string) {
+		return func(receipt *Receipt) (int, string) { return receipt.PointsForRoundDollar(amount) }
+	},
+	"cents_multiple_25": func(amount string) func(receipt *Receipt) (int, string) {
+		return func(receipt *Receipt) (int, string) { return receipt.PointsForCentsMultiple(amount) }
+	},
+}
+
+func (ruleset *Ruleset) WithAmount(amount string) (*Ruleset, error) {
+	if _, known := amountNames[amount]; !known {
+		return nil, fmt.Errorf("unknown amount %q, use %s, %s or %s", amount, AmountTotal, AmountSubtotal, AmountPreTip)
+	}
+	if amount == AmountTotal {
+		return ruleset, nil
+	}
+	derived := &Ruleset{Version: ruleset.Version + "+" + amount, currencies: ruleset.currencies, bonuses: ruleset.bonuses, campaigns: ruleset.campaigns}
+	for _, rule := range ruleset.Rules {
+		if amountRule, exists := amountRules[rule.Name]; exists {
+			rule.Receipt = amountRule(amount)
+		}
+		derived.Rules = append(derived.Rules, rule)
+	}
+	return derived, nil
+}
+
+func (ruleset *Ruleset) WithCurrencies(config *CurrencyConfig) *Ruleset {
+	derived := *ruleset
+	derived.currencies = config
+	return &derived
+}
+
+func (ruleset *Ruleset) WithBonuses(bonuses *Bonuses) *Ruleset {
+	derived := *ruleset
+	derived.bonuses = bonuses
+	return &derived
+}
+
+func (ruleset *Ruleset) BonusRules() []BonusRule {
+	if ruleset.bonuses == nil {
+		return nil
+	}
+	return ruleset.bonuses.Rules
+}
+
+func (ruleset *Ruleset) WithCampaigns(campaigns []Campaign) *Ruleset {
+	derived := *ruleset
+	derived.campaigns = campaigns
+	return &derived
+}
+
+// ActiveCampaigns gives the campaigns of the ruleset that apply to the
+// receipt, to record on it when it is stored.
+func (ruleset *Ruleset) ActiveCampaigns(receipt *Receipt) []Campaign {
+	scored := ruleset.prepare(receipt)
+	var active []Campaign
+	for _, campaign := range ruleset.campaigns {
+		if campaign.eligible(receipt, scored) {
+			active = append(active, campaign)
+		}
+	}
+	return active
+}
+
+func (ruleset *Ruleset) WithRules(rules ...Rule) (*Ruleset, error) {
+	derived := *ruleset
+	derived.Rules = slices.Clone(ruleset.Rules)
+	for _, rule := range rules {
+		if slices.ContainsFunc(derived.Rules, func(existing Rule) bool { return existing.Name == rule.Name }) {
+			return nil, fmt.Errorf("rule %s has the name of a rule in %s", rule.Name, ruleset.Version)
+		}
+		derived.Rules = append(derived.Rules, rule)
+	}
+	return &derived, nil
+}
+
+func (ruleset *Ruleset) Without(names ...string) (*Ruleset, error) {
+	derived := *ruleset
+	derived.Rules = nil
+	for _, name := range names {
+		if !slices.ContainsFunc(ruleset.Rules, func(rule Rule) bool { return rule.Name == name }) {
+			return nil, fmt.Errorf("%s has no rule %s", ruleset.Version, name)
+		}
+	}
+	for _, rule := range ruleset.Rules {
+		if !slices.Contains(names, rule.Name) {
+			derived.Rules = append(derived.Rules, rule)
+		}
+	}
+	return &derived, nil
+}
+
+// Rebased gives base's rules with the currencies, bonuses and campaigns of
+// ruleset.
+func (ruleset *Ruleset) Rebased(base *Ruleset) *Ruleset {
+	derived := *ruleset
+	derived.Version = base.Version
+	derived.Rules = base.Rules
+	return &derived
+}
+
+func (ruleset *Ruleset) WithExpressions(expressions *ExpressionRules) (*Ruleset, error) {
+	var rules []Rule
+	for _, rule := range expressions.Rules {
+		if rule.PerItem {
+			rules = append(rules, expressions.itemRule(rule))
+		} else {
+			rules = append(rules, expressions.receiptRule(rule))
+		}
+	}
+	return ruleset.WithRules(rules...)
+}
+
+func (ruleset *Ruleset) prepare(receipt *Receipt) *Receipt {
+	if ruleset.currencies == nil {
+		return receipt
+	}
+	currency := receipt.currency()
+	if rate, exists := ruleset.currencies.Rates[currency.Code]; exists && currency.Code != DefaultCurrency {
+		converted := receipt.Converted(rate)
+		return &converted
+	}
+	if thresholds, exists := ruleset.currencies.Thresholds[currency.Code]; exists {
+		prepared := *receipt
+		prepared.scored = &scoring{currency: currency, thresholds: thresholds}
+		return &prepared
+	}
+	return receipt
+}
+
+func (ruleset *Ruleset) Evaluate(ctx context.Context, receipt *Receipt) []RuleResult {
+	ctx, span := tracer.Start(ctx, "receipt.score", trace.WithAttributes(attribute.String("ruleset.version", ruleset.Version)))
+	defer span.End()
+	original := receipt
+	receipt = ruleset.prepare(receipt)
+	scored := receipt.scoring()
+
+	var results []RuleResult
+	evaluate := func(rule string, item *int, score func(ctx context.Context) (int, string)) {
+		ruleCtx, ruleSpan := tracer.Start(ctx, "rule "+rule)
+		points, message := score(ruleCtx)
+		ruleSpan.SetAttributes(attribute.Int("rule.points", points))
+		ruleSpan.End()
+		results = append(results, RuleResult{Rule: rule, Item: item, Points: points, Message: message})
+	}
+
+	for i := 0; i < len(ruleset.Rules); i++ {
+		rule := ruleset.Rules[i]
+		if rule.Item == nil {
+			evaluate(rule.Name, nil, func(ruleCtx context.Context) (int, string) {
+				if rule.ReceiptContext != nil {
+					return rule.ReceiptContext(ruleCtx, receipt)
+				}
+				return rule.Receipt(receipt)
+			})
+			continue
+		}
+		itemRules := []Rule{rule}
+		for i+1 < len(ruleset.Rules) && ruleset.Rules[i+1].Item != nil {
+			i++
+			itemRules = append(itemRules, ruleset.Rules[i])
+		}
+		for index, item := range receipt.Items {
+			item.scored = &scored
+			item.receipt = receipt
+			for _, itemRule := range itemRules {
+				evaluate(itemRule.Name, &index, func(context.Context) (int, string) { return itemRule.Item(&item) })
+			}
+		}
+	}
+
+	base := pointsOf(results)
+	if ruleset.bonuses != nil {
+		results = append(results, ruleset.bonuses.apply(receipt, base)...)
+	}
+	results = append(results, applyCampaigns(original.Campaigns, receipt, base)...)
+	results = original.Cap.Apply(results)
+
+	span.SetAttributes(attribute.Int("rules.evaluated", len(results)))
+	return results
+}
+
+func SummarizeRules(results []RuleResult) (int, []string) {
+	var breakdown []string
+	totalPoints := 0
+	for _, result := range results {
+		totalPoints += result.Points
+		breakdown = append(breakdown, result.Message)
+	}
+	return totalPoints, breakdown
+}
+
+func (receipt *Receipt) GetTotalPointsAndBreakdown() (int, []string) {
+	return SummarizeRules(StandardRuleset.Evaluate(context.Background(), receipt))
+}
@@ -0,0 +1,36 @@
+package receipt
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const retailerPunctuation = "-&_"
+const descriptionPunctuation = "-_"
+
+func validText(value string, punctuation string) bool {
+	for _, char := range norm.NFC.String(value) {
+		allowed := unicode.IsLetter(char) || unicode.IsMark(char) || unicode.IsNumber(char) || unicode.IsSpace(char) || strings.ContainsRune(punctuation, char)
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func characterCount(value string) int {
+	return utf8.RuneCountInString(norm.NFC.String(value))
+}
+
+func (receipt *Receipt) Normalize() {
+	receipt.Retailer = norm.NFC.String(receipt.Retailer)
+	for index := range receipt.Items {
+		receipt.Items[index].ShortDescription = norm.NFC.String(receipt.Items[index].ShortDescription)
+	}
+	for index := range receipt.Discounts {
+		receipt.Discounts[index].Description = norm.NFC.String(receipt.Discounts[index].Description)
+	}
+}
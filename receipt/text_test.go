@@ -0,0 +1,68 @@
+package receipt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUnicodeText(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Café Olé",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Müller Milch", Price: "1.25"},
+			{ShortDescription: "Crème brûlée", Price: "2.00"},
+		},
+		Total: "3.25",
+	}
+	err := receipt.Validate()
+	if err != nil {
+		t.Errorf("Should accept accented letters ... %s", err)
+	}
+
+	receipt.Retailer = "Trader Joe's"
+	receipt.Items[1].ShortDescription = "Milk, 1 gal."
+	err2 := receipt.Validate()
+	if err2 == nil || !strings.Contains(err2.Error(), "invalid format for retailer (Trader Joe's)") || !strings.Contains(err2.Error(), "invalid format for shortDescription (Milk, 1 gal.)") {
+		t.Errorf("Should reject punctuation by default ... %v", err2)
+	}
+	err3 := receipt.ValidateWith(ValidationOptions{Punctuation: "'.,"})
+	if err3 != nil {
+		t.Errorf("Should accept configured punctuation ... %s", err3)
+	}
+
+	receipt.Retailer = "Target <script>"
+	err4 := receipt.ValidateWith(ValidationOptions{Punctuation: "'.,"})
+	if err4 == nil || !strings.Contains(err4.Error(), "invalid format for retailer") {
+		t.Errorf("Should still reject other punctuation ... %v", err4)
+	}
+}
+
+func TestNormalizedCounts(t *testing.T) {
+	composed := Receipt{Retailer: "Café"}
+	decomposed := Receipt{Retailer: "Cafe\u0301"}
+	points, _ := composed.PointsForRetailerName()
+	points2, _ := decomposed.PointsForRetailerName()
+	if points != 4 || points2 != 4 {
+		t.Errorf("Should count 4 characters either way not %d and %d", points, points2)
+	}
+
+	hangul := Receipt{Retailer: "\u1100\u1161\u11a8"}
+	points3, _ := hangul.PointsForRetailerName()
+	if points3 != 1 {
+		t.Errorf("Should count the composed syllable once not %d", points3)
+	}
+
+	item := Item{ShortDescription: "Cafe\u0301 au lait", Price: "5.00"}
+	itemPoints, _ := item.PointsForItem()
+	if itemPoints != 1 {
+		t.Errorf("Should count 12 normalized characters not %d bytes", len(item.ShortDescription))
+	}
+
+	decomposed.Items = []Item{{ShortDescription: "Cre\u0300me"}}
+	decomposed.Normalize()
+	if decomposed.Retailer != "Caf\u00e9" || decomposed.Items[0].ShortDescription != "Cr\u00e8me" {
+		t.Errorf("Should normalize to NFC not %q %q", decomposed.Retailer, decomposed.Items[0].ShortDescription)
+	}
+}
@@ -0,0 +1,87 @@
+package receipt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const DefaultTimeZone = "UTC"
+
+type TimeZones struct {
+	Default   string            `json:"default"`
+	Retailers map[string]string `json:"retailers"`
+}
+
+func LoadTimeZones(filename string) (TimeZones, error) {
+	var zones TimeZones
+	err := LoadJSON(filename, &zones)
+	if err != nil {
+		return zones, err
+	}
+	if _, err2 := time.LoadLocation(zones.Default); err2 != nil {
+		return zones, fmt.Errorf("unknown default time zone %q", zones.Default)
+	}
+	for retailer, zone := range zones.Retailers {
+		if _, err3 := time.LoadLocation(zone); err3 != nil {
+			return zones, fmt.Errorf("unknown time zone %q for %s", zone, retailer)
+		}
+	}
+	return zones, nil
+}
+
+func (zones TimeZones) For(receipt *Receipt) string {
+	if receipt.TimeZone != "" {
+		return receipt.TimeZone
+	}
+	for retailer, zone := range zones.Retailers {
+		if strings.EqualFold(strings.TrimSpace(retailer), strings.TrimSpace(receipt.Retailer)) {
+			return zone
+		}
+	}
+	if zones.Default != "" {
+		return zones.Default
+	}
+	return DefaultTimeZone
+}
+
+func (receipt *Receipt) PurchaseInstant(zone string) (time.Time, error) {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", zone)
+	}
+	wall, err2 := time.Parse("2006-01-02 15:04", receipt.PurchaseDate+" "+receipt.PurchaseTime)
+	if err2 != nil {
+		return time.Time{}, err2
+	}
+	return inLocation(wall, location), nil
+}
+
+// inLocation reads a wall clock time in location. A time repeated when the
+// clocks go back is the first one, and a time skipped when they go forward is
+// read with the offset from before the change.
+func inLocation(wall time.Time, location *time.Location) time.Time {
+	var earliest time.Time
+	for _, probe := range []time.Duration{-12 * time.Hour, 12 * time.Hour} {
+		_, offset := wall.Add(probe).In(location).Zone()
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(location)
+		sameWall := candidate.Format(time.DateTime) == wall.Format(time.DateTime)
+		if sameWall && (earliest.IsZero() || candidate.Before(earliest)) {
+			earliest = candidate
+		}
+	}
+	if !earliest.IsZero() {
+		return earliest
+	}
+	_, before := wall.Add(-12 * time.Hour).In(location).Zone()
+	return wall.Add(-time.Duration(before) * time.Second).In(location)
+}
+
+func (receipt *Receipt) localPurchaseTime() (time.Time, bool) {
+	if receipt.PurchasedAt == "" {
+		return time.Time{}, false
+	}
+	instant, err := time.Parse(time.RFC3339, receipt.PurchasedAt)
+	return instant, err == nil
+}
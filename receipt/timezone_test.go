@@ -0,0 +1,101 @@
+package receipt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurchaseInstant(t *testing.T) {
+	cases := []struct {
+		date, clock, zone, expected string
+	}{
+		{"2022-01-01", "13:01", "", "2022-01-01T13:01:00Z"},
+		{"2022-07-01", "14:30", "America/New_York", "2022-07-01T14:30:00-04:00"},
+		{"2022-03-13", "02:30", "America/New_York", "2022-03-13T03:30:00-04:00"},
+		{"2022-11-06", "01:30", "America/New_York", "2022-11-06T01:30:00-04:00"},
+		{"2022-11-06", "02:30", "America/New_York", "2022-11-06T02:30:00-05:00"},
+		{"2022-10-02", "02:15", "Australia/Sydney", "2022-10-02T03:15:00+11:00"},
+		{"2022-04-03", "02:15", "Australia/Sydney", "2022-04-03T02:15:00+11:00"},
+	}
+	for _, c := range cases {
+		receipt := Receipt{PurchaseDate: c.date, PurchaseTime: c.clock}
+		instant, err := receipt.PurchaseInstant(c.zone)
+		if err != nil || instant.Format(time.RFC3339) != c.expected {
+			t.Errorf("Should read %s %s in %q as %s not %s ... %v", c.date, c.clock, c.zone, c.expected, instant.Format(time.RFC3339), err)
+		}
+	}
+
+	receipt := Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "13:01"}
+	_, err := receipt.PurchaseInstant("Mars/Olympus_Mons")
+	if err == nil {
+		t.Errorf("Should reject an unknown time zone")
+	}
+}
+
+func TestPurchaseTimeInLocalTime(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-03-13",
+		PurchaseTime: "14:00",
+		TimeZone:     "America/New_York",
+	}
+	instant, _ := receipt.PurchaseInstant(receipt.TimeZone)
+	receipt.PurchasedAt = instant.Format(time.RFC3339)
+	points, message := receipt.PointsForPurchaseTime()
+	if points != 0 || message != "0 points for time of purchase between 2pm and 4pm (14:00)" {
+		t.Errorf("Should keep the window exclusive ... %d %s", points, message)
+	}
+
+	receipt.PurchaseTime = "02:30"
+	receipt.PurchasedAt = "2022-03-13T03:30:00-04:00"
+	_, message2 := receipt.PointsForPurchaseTime()
+	if message2 != "0 points for time of purchase between 2pm and 4pm (03:30 -04:00)" {
+		t.Errorf("Should show the local time the clocks skipped to ... %s", message2)
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	zones := TimeZones{Default: "America/Chicago", Retailers: map[string]string{"Target": "America/Los_Angeles"}}
+	target := Receipt{Retailer: " target "}
+	other := Receipt{Retailer: "Walgreens"}
+	tagged := Receipt{Retailer: "Target", TimeZone: "Europe/Paris"}
+	if zones.For(&target) != "America/Los_Angeles" || zones.For(&other) != "America/Chicago" || zones.For(&tagged) != "Europe/Paris" {
+		t.Errorf("Should prefer the receipt, then the store, then the default zone")
+	}
+	if (TimeZones{}).For(&other) != "UTC" {
+		t.Errorf("Should fall back to UTC")
+	}
+
+	receipt := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+		Total:        "1.25",
+		TimeZone:     "Eastern",
+	}
+	err := receipt.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown time zone (Eastern)") {
+		t.Errorf("Should reject an unknown time zone ... %v", err)
+	}
+}
+
+func TestLoadTimeZones(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{"default": "America/Chicago", "retailers": {"Target": "America/Los_Angeles"}}`), 0o644)
+	zones, err := LoadTimeZones(valid)
+	if err != nil || zones.Retailers["Target"] != "America/Los_Angeles" {
+		t.Errorf("Should load the time zones ... %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"retailers": {"Target": "Pacific"}}`), 0o644)
+	_, err2 := LoadTimeZones(invalid)
+	if err2 == nil {
+		t.Errorf("Should reject an unknown time zone")
+	}
+}
@@ -0,0 +1,195 @@
+// Package retailers resolves the retailer names printed on receipts to
+// canonical retailers from a registry of names and aliases.
+package retailers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const DefaultThreshold = 0.8
+
+var rxStoreNumber = regexp.MustCompile(`(#\s*\d+|\b(?:no|store)\s*\.?\s*#?\s*\d+\b)`)
+var noiseWords = map[string]bool{"the": true, "store": true, "stores": true, "inc": true, "llc": true, "ltd": true, "co": true, "corp": true}
+
+type Retailer struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+type Match struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Score      float64 `json:"score"`
+	Registered bool    `json:"registered"`
+}
+
+type Registry struct {
+	retailers []Retailer
+	keys      map[string]int
+	threshold float64
+}
+
+type registryFile struct {
+	Threshold float64    `json:"threshold"`
+	Retailers []Retailer `json:"retailers"`
+}
+
+func New(retailers []Retailer, threshold float64) (*Registry, error) {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultThreshold
+	}
+	registry := &Registry{retailers: retailers, keys: make(map[string]int), threshold: threshold}
+	ids := make(map[string]bool)
+	for index, retailer := range retailers {
+		if retailer.ID == "" || retailer.Name == "" {
+			return nil, fmt.Errorf("retailer %d needs an id and a name", index)
+		}
+		if ids[retailer.ID] {
+			return nil, fmt.Errorf("duplicate retailer id %q", retailer.ID)
+		}
+		ids[retailer.ID] = true
+		for _, name := range append([]string{retailer.Name}, retailer.Aliases...) {
+			key := Normalize(name)
+			if other, exists := registry.keys[key]; exists && other != index {
+				return nil, fmt.Errorf("%q is used by both %s and %s", name, retailers[other].ID, retailer.ID)
+			}
+			registry.keys[key] = index
+		}
+	}
+	return registry, nil
+}
+
+func Load(filename string) (*Registry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading retailer registry: %w", err)
+	}
+	var file registryFile
+	err2 := json.Unmarshal(data, &file)
+	if err2 != nil {
+		return nil, fmt.Errorf("Error unmarshaling retailer registry: %w", err2)
+	}
+	return New(file.Retailers, file.Threshold)
+}
+
+// Match resolves a printed name: an exact match of the normalized name or an
+// alias first, then the closest name by edit distance or by containing all of
+// its words. Names that match nothing get an id made from the normalized name.
+func (registry *Registry) Match(name string) Match {
+	key := Normalize(name)
+	unregistered := Match{ID: strings.ReplaceAll(key, " ", "-"), Name: strings.TrimSpace(norm.NFC.String(name))}
+	if registry == nil {
+		return unregistered
+	}
+	if index, exists := registry.keys[key]; exists {
+		return registry.match(index, 1)
+	}
+
+	best, bestScore := -1, 0.0
+	for candidate, index := range registry.keys {
+		score := similarity(key, candidate)
+		if len(candidate) >= 4 && containsWords(key, candidate) {
+			score = max(score, 0.9)
+		}
+		if score > bestScore || (score == bestScore && best >= 0 && registry.retailers[index].ID < registry.retailers[best].ID) {
+			best, bestScore = index, score
+		}
+	}
+	if best >= 0 && bestScore >= registry.threshold {
+		return registry.match(best, bestScore)
+	}
+	return unregistered
+}
+
+func (registry *Registry) match(index int, score float64) Match {
+	retailer := registry.retailers[index]
+	return Match{ID: retailer.ID, Name: retailer.Name, Score: float64(int(score*100+0.5)) / 100, Registered: true}
+}
+
+func Normalize(name string) string {
+	lowered := strings.ToLower(norm.NFC.String(name))
+	lowered = strings.NewReplacer("'", "", "’", "").Replace(lowered)
+	lowered = rxStoreNumber.ReplaceAllString(lowered, " ")
+	cleaned := strings.Map(func(char rune) rune {
+		if unicode.IsLetter(char) || unicode.IsMark(char) || unicode.IsNumber(char) || char == '&' {
+			return char
+		}
+		return ' '
+	}, lowered)
+
+	var words []string
+	for _, word := range strings.Fields(cleaned) {
+		if !noiseWords[word] {
+			words = append(words, word)
+		}
+	}
+	for len(words) > 1 && isDigits(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func isDigits(word string) bool {
+	for _, char := range word {
+		if !unicode.IsDigit(char) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsWords(name string, candidate string) bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(name) {
+		words[word] = true
+	}
+	for _, word := range strings.Fields(candidate) {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+func similarity(a string, b string) float64 {
+	first, second := []rune(a), []rune(b)
+	longest := max(len(first), len(second))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(distance(first, second))/float64(longest)
+}
+
+// distance counts the insertions, deletions, substitutions and swaps of
+// neighbouring characters that turn first into second.
+func distance(first []rune, second []rune) int {
+	rows := make([][]int, len(first)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(second)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && first[i-1] == second[j-2] && first[i-2] == second[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(first)][len(second)]
+}
@@ -0,0 +1,100 @@
+package retailers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry, err := New([]Retailer{
+		{ID: "target", Name: "Target", Aliases: []string{"Target Supercenter"}},
+		{ID: "walgreens", Name: "Walgreens", Aliases: []string{"Walgreen Co"}},
+		{ID: "mm-corner-market", Name: "M&M Corner Market"},
+	}, 0)
+	if err != nil {
+		t.Fatalf("Should build the registry ... %s", err)
+	}
+	return registry
+}
+
+func TestNormalize(t *testing.T) {
+	expected := map[string]string{
+		"Target":              "target",
+		"TARGET #1234":        "target",
+		"Target Store":        "target",
+		"Target Store No. 12": "target",
+		"Target 0042":         "target",
+		"Trader Joe's":        "trader joes",
+		"M&M Corner Market":   "m&m corner market",
+		"7-Eleven":            "7 eleven",
+		"CAFÉ":                "café",
+	}
+	for name, normalized := range expected {
+		if Normalize(name) != normalized {
+			t.Errorf("Should normalize %q to %q not %q", name, normalized, Normalize(name))
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	registry := newTestRegistry(t)
+	expected := map[string]string{
+		"Target":                     "target",
+		"TARGET #1234":               "target",
+		"Target Supercenter":         "target",
+		"Taregt":                     "target",
+		"Walgreens Pharmacy":         "walgreens",
+		"WALGREEN CO.":               "walgreens",
+		"M & M Corner Market":        "mm-corner-market",
+		"Corner Store":               "corner",
+		"Trader Joe's Store #12":     "trader-joes",
+		"Walmart Neighborhood Mkt 5": "walmart-neighborhood-mkt",
+	}
+	for name, id := range expected {
+		if match := registry.Match(name); match.ID != id {
+			t.Errorf("Should match %q to %s not %+v", name, id, match)
+		}
+	}
+
+	match := registry.Match("TARGET #1234")
+	if !match.Registered || match.Name != "Target" || match.Score != 1 {
+		t.Errorf("Should match exactly not %+v", match)
+	}
+	fuzzy := registry.Match("Targte")
+	if !fuzzy.Registered || fuzzy.Score >= 1 {
+		t.Errorf("Should match fuzzily not %+v", fuzzy)
+	}
+	var empty *Registry
+	if unregistered := empty.Match("Target Store"); unregistered.ID != "target" || unregistered.Registered {
+		t.Errorf("Should derive an id without a registry not %+v", unregistered)
+	}
+}
+
+func TestNewRejectsConflicts(t *testing.T) {
+	_, err := New([]Retailer{{ID: "target", Name: "Target"}, {ID: "target", Name: "Target Corp"}}, 0)
+	if err == nil {
+		t.Errorf("Should reject duplicate ids")
+	}
+	_, err2 := New([]Retailer{{ID: "target", Name: "Target"}, {ID: "target-store", Name: "Target Store"}}, 0)
+	if err2 == nil {
+		t.Errorf("Should reject names that normalize to the same key")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "retailers.json")
+	os.WriteFile(filename, []byte(`{"threshold": 0.9, "retailers": [{"id": "target", "name": "Target", "aliases": ["Tgt"]}]}`), 0o644)
+	registry, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Should load the registry ... %s", err)
+	}
+	if registry.Match("tgt").ID != "target" || registry.Match("Targte").Registered {
+		t.Errorf("Should use the aliases and the threshold from the file")
+	}
+	_, err2 := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err2 == nil {
+		t.Errorf("Should fail for a missing file")
+	}
+}
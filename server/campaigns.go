@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"receipt-processor/receipt"
+)
+
+type campaigns struct {
+	mu      sync.Mutex
+	current atomic.Pointer[[]receipt.Campaign]
+}
+
+type campaignsResponse struct {
+	Campaigns []receipt.Campaign `json:"campaigns"`
+}
+
+func WithAdminToken(token string) Option {
+	return func(server *Server) {
+		server.adminToken = token
+	}
+}
+
+func (campaigns *campaigns) list() []receipt.Campaign {
+	current := campaigns.current.Load()
+	if current == nil {
+		return nil
+	}
+	return *current
+}
+
+func (campaigns *campaigns) find(id string) (receipt.Campaign, bool) {
+	for _, campaign := range campaigns.list() {
+		if campaign.ID == id {
+			return campaign, true
+		}
+	}
+	return receipt.Campaign{}, false
+}
+
+// update swaps in a changed copy of the campaigns, so a receipt is always
+// scored against one consistent set.
+func (campaigns *campaigns) update(change func(updated []receipt.Campaign) ([]receipt.Campaign, bool)) bool {
+	campaigns.mu.Lock()
+	defer campaigns.mu.Unlock()
+	updated, changed := change(slices.Clone(campaigns.list()))
+	if !changed {
+		return false
+	}
+	slices.SortFunc(updated, func(a receipt.Campaign, b receipt.Campaign) int {
+		if order := a.Start.Compare(b.Start); order != 0 {
+			return order
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	campaigns.current.Store(&updated)
+	return true
+}
+
+func (campaigns *campaigns) save(campaign receipt.Campaign, replace bool) bool {
+	return campaigns.update(func(updated []receipt.Campaign) ([]receipt.Campaign, bool) {
+		index := slices.IndexFunc(updated, func(existing receipt.Campaign) bool { return existing.ID == campaign.ID })
+		if index < 0 {
+			return append(updated, campaign), true
+		}
+		if !replace {
+			return nil, false
+		}
+		updated[index] = campaign
+		return updated, true
+	})
+}
+
+func (campaigns *campaigns) remove(id string) bool {
+	return campaigns.update(func(updated []receipt.Campaign) ([]receipt.Campaign, bool) {
+		index := slices.IndexFunc(updated, func(existing receipt.Campaign) bool { return existing.ID == id })
+		if index < 0 {
+			return nil, false
+		}
+		return slices.Delete(updated, index, index+1), true
+	})
+}
+
+func (server *Server) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if server.adminToken == "" {
+			handleError(writer, request, http.StatusForbidden, "Admin endpoints are disabled, set ADMIN_TOKEN to enable them")
+			return
+		}
+		token, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(server.adminToken)) != 1 {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			handleError(writer, request, http.StatusUnauthorized, "A valid admin token is required")
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+func (server *Server) decodeCampaign(request *http.Request, id string) (receipt.Campaign, error) {
+	var campaign receipt.Campaign
+	decoder := json.NewDecoder(request.Body)
+	if server.strictJSON {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(&campaign)
+	if err != nil {
+		return campaign, fmt.Errorf("Invalid campaign: %w", err)
+	}
+	if campaign.ID == "" {
+		campaign.ID = id
+	}
+	err2 := campaign.Compile()
+	if err2 != nil {
+		return campaign, fmt.Errorf("Invalid campaign: %w", err2)
+	}
+	return campaign, nil
+}
+
+func (server *Server) handleListCampaigns(writer http.ResponseWriter, request *http.Request) {
+	response := campaignsResponse{Campaigns: server.campaigns.list()}
+	if response.Campaigns == nil {
+		response.Campaigns = []receipt.Campaign{}
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+func (server *Server) handleCreateCampaign(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+	campaign, err := server.decodeCampaign(request, "")
+	if err != nil {
+		handleError(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !server.campaigns.save(campaign, false) {
+		message := fmt.Sprintf("campaign %s already exists", campaign.ID)
+		handleError(writer, request, http.StatusConflict, message)
+		return
+	}
+	writeJSON(writer, http.StatusCreated, campaign)
+}
+
+func (server *Server) handleGetCampaign(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	campaign, exists := server.campaigns.find(id)
+	if !exists {
+		message := fmt.Sprintf("campaign %s not found", id)
+		handleError(writer, request, http.StatusNotFound, message)
+		return
+	}
+	writeJSON(writer, http.StatusOK, campaign)
+}
+
+func (server *Server) handlePutCampaign(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+	id := request.PathValue("id")
+	campaign, err := server.decodeCampaign(request, id)
+	if err != nil {
+		handleError(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+	if campaign.ID != id {
+		message := fmt.Sprintf("campaign id %s does not match %s", campaign.ID, id)
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	server.campaigns.save(campaign, true)
+	writeJSON(writer, http.StatusOK, campaign)
+}
+
+func (server *Server) handleDeleteCampaign(writer http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if !server.campaigns.remove(id) {
+		message := fmt.Sprintf("campaign %s not found", id)
+		handleError(writer, request, http.StatusNotFound, message)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func adminRequest(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func getPoints(handler http.Handler, id string) string {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/points", nil))
+	return recorder.Body.String()
+}
+
+func TestCampaignEndpoints(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	before := postExample(t, handler, "../example2.json")
+
+	campaign := `{"id": "new-year", "description": "Double points for the new year", "retailer": "Target", "multiplier": 2, "start": "2022-01-01T00:00:00Z", "end": "2022-01-02T00:00:00Z"}`
+	created := adminRequest(handler, http.MethodPost, "/admin/campaigns", campaign)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("Should create the campaign not %d ... %s", created.Code, created.Body.String())
+	}
+	if duplicate := adminRequest(handler, http.MethodPost, "/admin/campaigns", campaign); duplicate.Code != http.StatusConflict {
+		t.Errorf("Should reject a duplicate id not %d", duplicate.Code)
+	}
+	if points := getPoints(handler, before); points != "{\"points\":28}\n" {
+		t.Errorf("Should not apply a new campaign to receipts stored before it ... %s", points)
+	}
+
+	id := postExample(t, handler, "../example2.json")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/breakdown", nil))
+	if !strings.Contains(recorder.Body.String(), "28 points from campaign new-year") || !strings.Contains(recorder.Body.String(), "56 points total") {
+		t.Errorf("Should apply the campaign to the breakdown ... %s", recorder.Body.String())
+	}
+
+	replaced := adminRequest(handler, http.MethodPut, "/admin/campaigns/new-year", `{"points": 10, "start": "2022-01-01T00:00:00Z", "end": "2022-01-02T00:00:00Z"}`)
+	if replaced.Code != http.StatusOK || !strings.Contains(replaced.Body.String(), `"id":"new-year"`) {
+		t.Errorf("Should replace the campaign not %d ... %s", replaced.Code, replaced.Body.String())
+	}
+	listed := adminRequest(handler, http.MethodGet, "/admin/campaigns", "")
+	if !strings.Contains(listed.Body.String(), `"points":10`) || strings.Contains(listed.Body.String(), "multiplier") {
+		t.Errorf("Should list the replaced campaign ... %s", listed.Body.String())
+	}
+	if points := getPoints(handler, postExample(t, handler, "../example2.json")); points != "{\"points\":38}\n" {
+		t.Errorf("Should apply the replaced campaign to new receipts ... %s", points)
+	}
+
+	if deleted := adminRequest(handler, http.MethodDelete, "/admin/campaigns/new-year", ""); deleted.Code != http.StatusNoContent {
+		t.Errorf("Should delete the campaign not %d", deleted.Code)
+	}
+	if missing := adminRequest(handler, http.MethodGet, "/admin/campaigns/new-year", ""); missing.Code != http.StatusNotFound {
+		t.Errorf("Should not find the deleted campaign not %d", missing.Code)
+	}
+	if points := getPoints(handler, id); points != "{\"points\":56}\n" {
+		t.Errorf("Should keep the points of the campaign the receipt was stored under ... %s", points)
+	}
+}
+
+func TestCampaignEndpointsRejectBadRequests(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	invalid := adminRequest(handler, http.MethodPost, "/admin/campaigns", `{"id": "a", "points": 1, "start": "2022-01-02T00:00:00Z", "end": "2022-01-01T00:00:00Z"}`)
+	if invalid.Code != http.StatusBadRequest {
+		t.Errorf("Should reject an end before the start not %d", invalid.Code)
+	}
+	mismatched := adminRequest(handler, http.MethodPut, "/admin/campaigns/a", `{"id": "b", "points": 1, "start": "2022-01-01T00:00:00Z", "end": "2022-01-02T00:00:00Z"}`)
+	if mismatched.Code != http.StatusBadRequest {
+		t.Errorf("Should reject an id that does not match the path not %d", mismatched.Code)
+	}
+
+	unauthorized := httptest.NewRecorder()
+	handler.ServeHTTP(unauthorized, httptest.NewRequest(http.MethodGet, "/admin/campaigns", nil))
+	if unauthorized.Code != http.StatusUnauthorized {
+		t.Errorf("Should require the admin token not %d", unauthorized.Code)
+	}
+	disabled := adminRequest(newTestServer().Handler(), http.MethodGet, "/admin/campaigns", "")
+	if disabled.Code != http.StatusForbidden {
+		t.Errorf("Should disable admin endpoints without a token not %d", disabled.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"receipt-processor/exporter"
+	"receipt-processor/receipt"
+	"receipt-processor/storage"
+)
+
+const defaultListLimit = 100
+const maxListLimit = 1000
+
+var errListFull = errors.New("list is full")
+
+type listedReceipt struct {
+	ID           string `json:"id"`
+	Retailer     string `json:"retailer"`
+	RetailerID   string `json:"retailerId"`
+	PurchaseDate string `json:"purchaseDate"`
+	PurchaseTime string `json:"purchaseTime"`
+	Total        string `json:"total"`
+	Points       int    `json:"points"`
+}
+
+type listResponse struct {
+	Receipts []listedReceipt `json:"receipts"`
+}
+
+func (server *Server) parseFilter(request *http.Request) (storage.Filter, error) {
+	query := request.URL.Query()
+	return server.resolveFilter(storage.Filter{
+		Retailer:   query.Get("retailer"),
+		RetailerID: query.Get("retailerId"),
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+	})
+}
+
+func (server *Server) resolveFilter(filter storage.Filter) (storage.Filter, error) {
+	if filter.Retailer != "" && filter.RetailerID == "" {
+		filter.RetailerID = server.retailers.Match(filter.Retailer).ID
+	}
+	for name, value := range map[string]string{"from": filter.From, "to": filter.To} {
+		if value == "" {
+			continue
+		}
+		_, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a YYYY-MM-DD date (%s)", name, value)
+		}
+	}
+	return filter, nil
+}
+
+func queryInt(request *http.Request, name string, defaultValue int, maxValue int) (int, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 || parsed > maxValue {
+		return 0, fmt.Errorf("%s must be a whole number from 0 to %d (%s)", name, maxValue, value)
+	}
+	return parsed, nil
+}
+
+func (server *Server) handleListReceipts(writer http.ResponseWriter, request *http.Request) {
+	filter, err := server.parseFilter(request)
+	if err != nil {
+		handleError(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err2 := queryInt(request, "limit", defaultListLimit, maxListLimit)
+	if err2 != nil {
+		handleError(writer, request, http.StatusBadRequest, err2.Error())
+		return
+	}
+	offset, err3 := queryInt(request, "offset", 0, math.MaxInt)
+	if err3 != nil {
+		handleError(writer, request, http.StatusBadRequest, err3.Error())
+		return
+	}
+
+	ctx := request.Context()
+	ruleset := server.Ruleset()
+	response := listResponse{Receipts: []listedReceipt{}}
+	skipped := 0
+	err4 := server.store.List(ctx, filter, func(id string, stored receipt.Receipt) error {
+		if skipped < offset {
+			skipped++
+			return nil
+		}
+		if len(response.Receipts) >= limit {
+			return errListFull
+		}
+		points, _ := receipt.SummarizeRules(ruleset.Evaluate(ctx, &stored))
+		response.Receipts = append(response.Receipts, listedReceipt{
+			ID:           id,
+			Retailer:     stored.Retailer,
+			RetailerID:   stored.RetailerID,
+			PurchaseDate: stored.PurchaseDate,
+			PurchaseTime: stored.PurchaseTime,
+			Total:        stored.Total,
+			Points:       points,
+		})
+		return nil
+	})
+	if err4 != nil && err4 != errListFull {
+		message := fmt.Sprintf("Could not list receipts: %s", err4.Error())
+		handleError(writer, request, http.StatusInternalServerError, message)
+		return
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+func (server *Server) handleExport(writer http.ResponseWriter, request *http.Request) {
+	filter, err := server.parseFilter(request)
+	if err != nil {
+		handleError(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows := request.URL.Query().Get("rows")
+	if rows == "" {
+		rows = exporter.RowsPerReceipt
+	}
+	if rows != exporter.RowsPerReceipt && rows != exporter.RowsPerItem {
+		message := fmt.Sprintf("rows must be %s or %s (%s)", exporter.RowsPerReceipt, exporter.RowsPerItem, rows)
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+
+	ruleset := server.Ruleset()
+	ctx := request.Context()
+	var exportWriter exporter.Writer
+	format := request.URL.Query().Get("format")
+	switch format {
+	case "", "csv":
+		format = "csv"
+		var campaigns []receipt.Campaign
+		capped := false
+		err2 := server.store.List(ctx, filter, func(id string, stored receipt.Receipt) error {
+			campaigns = append(campaigns, stored.Campaigns...)
+			capped = capped || stored.Cap != nil
+			return nil
+		})
+		if err2 != nil {
+			message := fmt.Sprintf("Could not list receipts: %s", err2.Error())
+			handleError(writer, request, http.StatusInternalServerError, message)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/csv")
+		csvWriter, err3 := exporter.NewCSVWriter(writer, exporter.RuleNames(ruleset, campaigns, capped, rows), rows)
+		if err3 != nil {
+			setRequestError(request, err3.Error())
+			return
+		}
+		exportWriter = csvWriter
+	case "jsonl":
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+		exportWriter = exporter.NewJSONLWriter(writer)
+	default:
+		message := fmt.Sprintf("format must be csv or jsonl (%s)", format)
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="receipts.%s"`, format))
+
+	controller := http.NewResponseController(writer)
+	err4 := server.store.List(ctx, filter, func(id string, stored receipt.Receipt) error {
+		for _, record := range exporter.Records(ctx, ruleset, id, stored, rows) {
+			err5 := exportWriter.Write(record)
+			if err5 != nil {
+				return err5
+			}
+		}
+		err6 := controller.Flush()
+		if err6 != nil && !errors.Is(err6, http.ErrNotSupported) {
+			return err6
+		}
+		return nil
+	})
+	if err4 == nil {
+		err4 = exportWriter.Close()
+	}
+	if err4 != nil {
+		setRequestError(request, fmt.Sprintf("Export stopped: %s", err4.Error()))
+	}
+}
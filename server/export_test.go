@@ -0,0 +1,184 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"receipt-processor/retailers"
+)
+
+func TestListReceiptsFilters(t *testing.T) {
+	handler := newTestServer().Handler()
+	id1 := postExample(t, handler, "../example1.json")
+	id2 := postExample(t, handler, "../example2.json")
+	id3 := postExample(t, handler, "../example3.json")
+
+	expected := map[string][]string{
+		"/receipts":                               {id1, id2, id3},
+		"/receipts?retailer=target":               {id2},
+		"/receipts?from=2022-01-02":               {id1, id3},
+		"/receipts?to=2022-01-02":                 {id1, id2},
+		"/receipts?limit=1&offset=1":              {id2},
+		"/receipts?from=2022-01-02&limit=0":       {},
+		"/receipts?retailer=Nobody&to=2030-01-01": {},
+	}
+	for path, ids := range expected {
+		recorder := serve(handler, http.MethodGet, path, "", "", "")
+		var listed listResponse
+		json.Unmarshal(recorder.Body.Bytes(), &listed)
+		var got []string
+		for _, entry := range listed.Receipts {
+			got = append(got, entry.ID)
+		}
+		if recorder.Code != http.StatusOK || strings.Join(got, ",") != strings.Join(ids, ",") {
+			t.Errorf("(%d) %s should list %v not %v", recorder.Code, path, ids, got)
+		}
+	}
+
+	recorder := serve(handler, http.MethodGet, "/receipts?retailer=Target", "", "", "")
+	if !strings.Contains(recorder.Body.String(), `"points":28`) {
+		t.Errorf("Should include points in the listing ... %s", recorder.Body.String())
+	}
+	for _, path := range []string{"/receipts?from=01/02/2022", "/receipts?limit=5000", "/receipts?offset=-1"} {
+		recorder2 := serve(handler, http.MethodGet, path, "", "", "")
+		if recorder2.Code != http.StatusBadRequest {
+			t.Errorf("%s should have 400 response not %d", path, recorder2.Code)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	handler := newTestServer().Handler()
+	id := postExample(t, handler, "../example1.json")
+	postExample(t, handler, "../example2.json")
+
+	recorder := serve(handler, http.MethodGet, "/receipts/export?retailer=walgreens", "", "", "")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("(%d) Should have a CSV response not %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Should have a header and 1 row ... %s", recorder.Body.String())
+	}
+	if !strings.HasPrefix(lines[0], "id,retailer,retailerId,purchaseDate,purchaseTime,purchasedAt,total,currency,items,discounts,subtotal,tax,tip,points,rule_retailer_name,") {
+		t.Errorf("Should have the receipt header ... %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], id+",Walgreens,walgreens,2022-01-02,08:13,2022-01-02T08:13:00Z,2.65,USD,2,,,,,15,9,") {
+		t.Errorf("Should have the Walgreens row ... %s", lines[1])
+	}
+}
+
+func TestExportCSVKeepsDeletedCampaignColumns(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	campaign := `{"id": "new-year", "retailer": "Target", "multiplier": 2, "start": "2022-01-01T00:00:00Z", "end": "2022-01-02T00:00:00Z"}`
+	if created := adminRequest(handler, http.MethodPost, "/admin/campaigns", campaign); created.Code != http.StatusCreated {
+		t.Fatalf("Should create the campaign not %d ... %s", created.Code, created.Body.String())
+	}
+	postExample(t, handler, "../example1.json")
+	postExample(t, handler, "../example2.json")
+	if deleted := adminRequest(handler, http.MethodDelete, "/admin/campaigns/new-year", ""); deleted.Code != http.StatusNoContent {
+		t.Fatalf("Should delete the campaign not %d", deleted.Code)
+	}
+
+	recorder := serve(handler, http.MethodGet, "/receipts/export", "", "", "")
+	rows, err := csv.NewReader(strings.NewReader(recorder.Body.String())).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("Should have a header and 2 rows ... %v %s", err, recorder.Body.String())
+	}
+	header := rows[0]
+	if !strings.Contains(strings.Join(header, ","), "rule_campaign_new-year") {
+		t.Errorf("Should keep a column for the deleted campaign ... %v", header)
+	}
+	for _, row := range rows[1:] {
+		points, sum := 0, 0
+		for index, column := range header {
+			value, _ := strconv.Atoi(row[index])
+			if column == "points" {
+				points = value
+			} else if strings.HasPrefix(column, "rule_") {
+				sum += value
+			}
+		}
+		if points != sum {
+			t.Errorf("Should add the rule columns up to the %d points not %d ... %v", points, sum, row)
+		}
+	}
+}
+
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (recorder *flushRecorder) Flush() {
+	recorder.flushed = append(recorder.flushed, recorder.Body.String())
+}
+
+func TestExportFlushesEachReceipt(t *testing.T) {
+	handler := newTestServer().Handler()
+	postExample(t, handler, "../example1.json")
+	postExample(t, handler, "../example2.json")
+
+	recorder := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/export?format=jsonl", nil))
+	if len(recorder.flushed) != 2 {
+		t.Fatalf("Should flush after each receipt not %d times", len(recorder.flushed))
+	}
+	if strings.Count(recorder.flushed[0], "\n") != 1 || !strings.Contains(recorder.flushed[0], "Walgreens") || strings.Contains(recorder.flushed[0], "Target") {
+		t.Errorf("Should flush the first receipt before scoring the second ... %s", recorder.flushed[0])
+	}
+}
+
+func TestExportJSONLPerItem(t *testing.T) {
+	handler := newTestServer().Handler()
+	postExample(t, handler, "../example2.json")
+
+	recorder := serve(handler, http.MethodGet, "/receipts/export?format=jsonl&rows=item", "", "", "")
+	if recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Should have a JSON Lines response not %s", recorder.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Should have 5 item rows ... %s", recorder.Body.String())
+	}
+	var record struct {
+		Item             int            `json:"item"`
+		ShortDescription string         `json:"shortDescription"`
+		Points           int            `json:"points"`
+		Rules            map[string]int `json:"rules"`
+	}
+	json.Unmarshal([]byte(lines[4]), &record)
+	if record.Item != 4 || record.ShortDescription != "   Klarbrunn 12-PK 12 FL OZ  " || record.Points != 3 || record.Rules["item_description"] != 3 {
+		t.Errorf("Should have the Klarbrunn row with 3 points ... %s", lines[4])
+	}
+}
+
+func TestExportRejectsBadParameters(t *testing.T) {
+	handler := newTestServer().Handler()
+	for _, path := range []string{"/receipts/export?format=xlsx", "/receipts/export?rows=rule", "/receipts/export?to=tomorrow"} {
+		recorder := serve(handler, http.MethodGet, path, "", "", "")
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s should have 400 response not %d", path, recorder.Code)
+		}
+	}
+}
+
+func TestListReceiptsByCanonicalRetailer(t *testing.T) {
+	registry, _ := retailers.New([]retailers.Retailer{{ID: "tgt", Name: "Target", Aliases: []string{"Target Supercenter"}}}, 0)
+	handler := newTestServer(WithRetailers(registry)).Handler()
+	postExample(t, handler, "../example2.json")
+	postExample(t, handler, "../example1.json")
+
+	for _, path := range []string{"/receipts?retailer=TARGET%20%231234", "/receipts?retailer=target+supercenter", "/receipts?retailerId=tgt"} {
+		recorder := serve(handler, http.MethodGet, path, "", "", "")
+		body := recorder.Body.String()
+		if strings.Count(body, `"retailerId":"tgt"`) != 1 || strings.Contains(body, "Walgreens") {
+			t.Errorf("%s should list the Target receipt only ... %s", path, body)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+func (server *Server) BuildInfo() map[string]string {
+	gitCommit := server.commit
+	if gitCommit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					gitCommit = setting.Value
+				}
+			}
+		}
+	}
+	if gitCommit == "" {
+		gitCommit = "unknown"
+	}
+	return map[string]string{
+		"version":        server.version,
+		"commit":         gitCommit,
+		"goVersion":      runtime.Version(),
+		"rulesetVersion": server.ruleset.Load().Version,
+	}
+}
+
+func (server *Server) handleHealthz(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (server *Server) handleReadyz(writer http.ResponseWriter, request *http.Request) {
+	ctx, cancel := context.WithTimeout(request.Context(), time.Second)
+	defer cancel()
+
+	checks := map[string]string{
+		"store":    "ok",
+		"ruleset":  "ok",
+		"draining": "no",
+	}
+	ready := true
+	err := server.store.Ping(ctx)
+	if err != nil {
+		checks["store"] = err.Error()
+		ready = false
+	}
+	ruleset := server.ruleset.Load()
+	if ruleset == nil || len(ruleset.Rules) == 0 {
+		checks["ruleset"] = "not loaded"
+		ready = false
+	}
+	if server.draining.Load() {
+		checks["draining"] = "yes"
+		ready = false
+	}
+
+	status := "ready"
+	statusCode := http.StatusOK
+	if !ready {
+		status = "not ready"
+		statusCode = http.StatusServiceUnavailable
+	}
+	writeJSON(writer, statusCode, map[string]interface{}{"status": status, "checks": checks})
+}
+
+func (server *Server) handleVersion(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, server.BuildInfo())
+}
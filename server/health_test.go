@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"receipt-processor/receipt"
+)
+
+func TestHealthz(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newTestServer().handleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d", recorder.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newTestServer().handleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestReadyzDraining(t *testing.T) {
+	server := newTestServer()
+	server.SetDraining(true)
+	recorder := httptest.NewRecorder()
+	server.handleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Should have 503 response while draining not %d", recorder.Code)
+	}
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	json.NewDecoder(recorder.Body).Decode(&body)
+	if body.Checks["draining"] != "yes" {
+		t.Errorf("Draining check should be 'yes' ... %v", body.Checks)
+	}
+}
+
+func TestReadyzNoRuleset(t *testing.T) {
+	server := newTestServer(WithRuleset(&receipt.Ruleset{Version: "empty"}))
+	recorder := httptest.NewRecorder()
+	server.handleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Should have 503 response without a ruleset not %d", recorder.Code)
+	}
+
+	server.ruleset.Store(nil)
+	unloaded := httptest.NewRecorder()
+	server.handleReadyz(unloaded, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if unloaded.Code != http.StatusServiceUnavailable {
+		t.Errorf("Should have 503 response before a ruleset is loaded not %d", unloaded.Code)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newTestServer().handleVersion(recorder, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var body map[string]string
+	json.NewDecoder(recorder.Body).Decode(&body)
+	if body["goVersion"] != runtime.Version() {
+		t.Errorf("Should report Go version %s ... %v", runtime.Version(), body)
+	}
+	if body["rulesetVersion"] != receipt.StandardRuleset.Version {
+		t.Errorf("Should report ruleset version %s ... %v", receipt.StandardRuleset.Version, body)
+	}
+	if body["version"] == "" || body["commit"] == "" {
+		t.Errorf("Should report version and commit ... %v", body)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"receipt-processor/importer"
+)
+
+const contentTypeCSV = "text/csv"
+
+type importedReceipt struct {
+	ID   string `json:"id"`
+	Rows []int  `json:"rows"`
+}
+
+type importResponse struct {
+	Imported []importedReceipt   `json:"imported"`
+	Errors   []importer.RowError `json:"errors"`
+}
+
+func (server *Server) handleReceiptImport(writer http.ResponseWriter, request *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || mediaType != contentTypeCSV {
+		message := fmt.Sprintf("Content-Type %s is not supported, use %s", request.Header.Get("Content-Type"), contentTypeCSV)
+		handleError(writer, request, http.StatusUnsupportedMediaType, message)
+		return
+	}
+	defer request.Body.Close()
+	user, err2 := server.userFrom(request)
+	if err2 != nil {
+		handleError(writer, request, http.StatusUnauthorized, err2.Error())
+		return
+	}
+
+	ctx := request.Context()
+	_, importSpan := tracer.Start(ctx, "receipt.import")
+	result, err3 := importer.ImportCSV(request.Body, server.validation)
+	if err3 != nil {
+		recordSpanError(importSpan, err3)
+		importSpan.End()
+		handleError(writer, request, http.StatusBadRequest, fmt.Sprintf("Invalid CSV: %s", err3.Error()))
+		return
+	}
+	importSpan.SetAttributes(attribute.Int("import.receipts", len(result.Receipts)), attribute.Int("import.errors", len(result.Errors)))
+	importSpan.End()
+
+	response := importResponse{Imported: []importedReceipt{}, Errors: result.Errors}
+	for _, imported := range result.Receipts {
+		id, err4 := server.storeReceipt(ctx, imported.Receipt, user)
+		if err4 != nil {
+			for _, row := range imported.Rows {
+				response.Errors = append(response.Errors, importer.RowError{Row: row, Message: fmt.Sprintf("Could not store receipt: %s", err4.Error())})
+			}
+			continue
+		}
+		response.Imported = append(response.Imported, importedReceipt{ID: id, Rows: imported.Rows})
+	}
+	slices.SortStableFunc(response.Errors, func(a, b importer.RowError) int { return a.Row - b.Row })
+	writeJSON(writer, http.StatusOK, response)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"receipt-processor/receipt"
+	"receipt-processor/storage"
+)
+
+const importCSV = `retailer,purchaseDate,purchaseTime,total,shortDescription,price
+Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25
+Walgreens,2022-01-02,08:13,2.65,Dasani,1.40
+Target,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.2
+`
+
+func TestImportCSVReceipts(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/import", "text/csv; charset=utf-8", "", importCSV)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	var imported importResponse
+	json.Unmarshal(recorder.Body.Bytes(), &imported)
+	if len(imported.Imported) != 1 || len(imported.Imported[0].Rows) != 2 {
+		t.Fatalf("Should import the Walgreens receipt from rows 2-3 ... %s", recorder.Body.String())
+	}
+	if len(imported.Errors) != 2 || imported.Errors[0].Row != 4 {
+		t.Errorf("Should report errors on row 4 ... %s", recorder.Body.String())
+	}
+
+	recorder2 := serve(handler, http.MethodGet, "/receipts/"+imported.Imported[0].ID+"/points", "", "", "")
+	if recorder2.Body.String() != "{\"points\":15}\n" {
+		t.Errorf("Should have 15 points for the imported receipt ... %s", recorder2.Body.String())
+	}
+}
+
+func TestImportRejectsOtherContentTypes(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/import", "application/json", "", "{}")
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Should have 415 response not %d", recorder.Code)
+	}
+	recorder2 := serve(handler, http.MethodPost, "/receipts/import", "text/csv", "", "retailer,total\n")
+	if recorder2.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response for a bad header not %d", recorder2.Code)
+	}
+}
+
+type failingStore struct {
+	*storage.MemoryStore
+	saves int
+}
+
+func (store *failingStore) Save(ctx context.Context, id string, saved receipt.Receipt) error {
+	if store.saves == 0 {
+		return errors.New("disk full")
+	}
+	store.saves--
+	return store.MemoryStore.Save(ctx, id, saved)
+}
+
+func TestImportReportsReceiptsThatCouldNotBeStored(t *testing.T) {
+	handler := New(&failingStore{MemoryStore: storage.NewMemoryStore(), saves: 1}, WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))).Handler()
+	body := importCSV + "Target,2022-01-03,13:13,1.25,Pepsi - 12-oz,1.25\n"
+	recorder := serve(handler, http.MethodPost, "/receipts/import", "text/csv", "", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	var imported importResponse
+	json.Unmarshal(recorder.Body.Bytes(), &imported)
+	if len(imported.Imported) != 1 || imported.Imported[0].Rows[0] != 2 {
+		t.Errorf("Should report the id of the receipt stored before the failure ... %s", recorder.Body.String())
+	}
+	last := imported.Errors[len(imported.Errors)-1]
+	if len(imported.Errors) != 3 || last.Row != 5 || last.Message != "Could not store receipt: disk full" {
+		t.Errorf("Should report the row that could not be stored ... %s", recorder.Body.String())
+	}
+}
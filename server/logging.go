@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var rxRequestID = regexp.MustCompile(`^[\w\-.:]{1,128}$`)
+
+type requestInfoKey struct{}
+
+type requestInfo struct {
+	id        string
+	receiptID string
+	err       string
+}
+
+func LogLevel() slog.Level {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL")))
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+	return info
+}
+
+func requestIDFrom(ctx context.Context) string {
+	return requestInfoFrom(ctx).id
+}
+
+func setReceiptID(request *http.Request, receiptID string) {
+	requestInfoFrom(request.Context()).receiptID = receiptID
+}
+
+func setRequestError(request *http.Request, message string) {
+	requestInfoFrom(request.Context()).err = message
+}
+
+func (server *Server) requestLogger(request *http.Request) *slog.Logger {
+	return server.logger.With(
+		"request_id", requestIDFrom(request.Context()),
+		"method", request.Method,
+		"path", request.URL.Path,
+	)
+}
+
+func (server *Server) logRequests(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		id := request.Header.Get("X-Request-ID")
+		if !rxRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		info := &requestInfo{id: id}
+		request = request.WithContext(context.WithValue(request.Context(), requestInfoKey{}, info))
+		writer.Header().Set("X-Request-ID", id)
+
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if recorder.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.Int("status", recorder.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if info.receiptID != "" {
+			attrs = append(attrs, slog.String("receipt_id", info.receiptID))
+		}
+		if info.err != "" {
+			attrs = append(attrs, slog.String("error", info.err))
+		}
+		spanContext := trace.SpanContextFromContext(request.Context())
+		if spanContext.IsValid() {
+			attrs = append(attrs,
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+		server.requestLogger(request).LogAttrs(request.Context(), level, "request completed", attrs...)
+	}
+}
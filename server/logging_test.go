@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureLogs() (*Server, *bytes.Buffer) {
+	var buffer bytes.Buffer
+	server := newTestServer(WithLogger(slog.New(slog.NewJSONHandler(&buffer, nil))))
+	return server, &buffer
+}
+
+func TestLogRequestsEchoesRequestID(t *testing.T) {
+	server, _ := captureLogs()
+	handler := server.Handler()
+	request := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil)
+	request.Header.Set("X-Request-ID", "req-42")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Header().Get("X-Request-ID") != "req-42" {
+		t.Errorf("Should echo X-Request-ID not '%s'", recorder.Header().Get("X-Request-ID"))
+	}
+	var body map[string]string
+	json.NewDecoder(recorder.Body).Decode(&body)
+	if body["request_id"] != "req-42" {
+		t.Errorf("Error body should contain request_id 'req-42' ... %v", body)
+	}
+}
+
+func TestLogRequestsGeneratesRequestID(t *testing.T) {
+	server, _ := captureLogs()
+	handler := server.Handler()
+	request := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil)
+	request.Header.Set("X-Request-ID", "not a valid id!")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	requestID := recorder.Header().Get("X-Request-ID")
+	if requestID == "" || requestID == "not a valid id!" {
+		t.Errorf("Should generate a new X-Request-ID not '%s'", requestID)
+	}
+}
+
+func TestLogRequestsWritesStructuredLine(t *testing.T) {
+	server, buffer := captureLogs()
+	handler := server.Handler()
+	request := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/breakdown", nil)
+	request.Header.Set("X-Request-ID", "req-43")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	var line map[string]interface{}
+	err := json.Unmarshal([]byte(strings.TrimSpace(buffer.String())), &line)
+	if err != nil {
+		t.Fatalf("Log line should be JSON ... %s", buffer.String())
+	}
+	expected := map[string]interface{}{
+		"level":      "WARN",
+		"request_id": "req-43",
+		"method":     "GET",
+		"path":       "/receipts/abc-123/breakdown",
+		"status":     float64(404),
+		"receipt_id": "abc-123",
+		"error":      "receipt abc-123 not found",
+	}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("Log line should have %s=%v not %v", key, value, line[key])
+		}
+	}
+	if _, ok := line["latency_ms"]; !ok {
+		t.Errorf("Log line should have latency_ms ... %v", line)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"receipt-processor/receipt"
+	"receipt-processor/storage"
+)
+
+type metrics struct {
+	handler                 http.Handler
+	httpRequestsTotal       *prometheus.CounterVec
+	httpRequestDuration     *prometheus.HistogramVec
+	validationFailuresTotal *prometheus.CounterVec
+	receiptsStoredTotal     prometheus.Counter
+	pointsAwarded           prometheus.Histogram
+	ruleHitsTotal           *prometheus.CounterVec
+}
+
+func newMetrics(store storage.ReceiptStore) *metrics {
+	m := &metrics{
+		httpRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "receipt_processor",
+				Name:      "http_requests_total",
+				Help:      "Number of HTTP requests by route, method and status code.",
+			},
+			[]string{"route", "method", "status"},
+		),
+		httpRequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "receipt_processor",
+				Name:      "http_request_duration_seconds",
+				Help:      "Latency of HTTP requests by route, method and status code.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"route", "method", "status"},
+		),
+		validationFailuresTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "receipt_processor",
+				Name:      "validation_failures_total",
+				Help:      "Number of receipt validation problems by field and reason.",
+			},
+			[]string{"field", "reason"},
+		),
+		receiptsStoredTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "receipt_processor",
+				Name:      "receipts_stored_total",
+				Help:      "Number of receipts that passed validation and were stored.",
+			},
+		),
+		pointsAwarded: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "receipt_processor",
+				Name:      "points_awarded",
+				Help:      "Total points awarded per stored receipt.",
+				Buckets:   []float64{0, 10, 25, 50, 75, 100, 150, 200, 300, 500},
+			},
+		),
+		ruleHitsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "receipt_processor",
+				Name:      "rule_hits_total",
+				Help:      "Number of times a scoring rule awarded a non-zero number of points.",
+			},
+			[]string{"rule"},
+		),
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestsTotal,
+		m.httpRequestDuration,
+		m.validationFailuresTotal,
+		m.receiptsStoredTotal,
+		m.pointsAwarded,
+		m.ruleHitsTotal,
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "receipt_processor",
+				Name:      "receipts_in_store",
+				Help:      "Number of receipts currently held in the data store.",
+			},
+			func() float64 {
+				count, _ := store.Count(context.Background())
+				return float64(count)
+			},
+		),
+	)
+	m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return m
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.status = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap lets http.ResponseController reach the writer underneath, so
+// streaming handlers can flush through the middleware.
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+func (server *Server) instrument(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request)
+		status := strconv.Itoa(recorder.status)
+		server.metrics.httpRequestsTotal.WithLabelValues(route, request.Method, status).Inc()
+		server.metrics.httpRequestDuration.WithLabelValues(route, request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *metrics) recordValidationFailure(err error) {
+	var validationError *receipt.ValidationError
+	if !errors.As(err, &validationError) {
+		return
+	}
+	for _, problem := range validationError.Problems {
+		m.validationFailuresTotal.WithLabelValues(problem.Field, problem.Reason).Inc()
+	}
+}
+
+func (m *metrics) recordScoring(results []receipt.RuleResult) {
+	totalPoints := 0
+	for _, result := range results {
+		totalPoints += result.Points
+		if result.Points > 0 {
+			m.ruleHitsTotal.WithLabelValues(result.Rule).Inc()
+		}
+	}
+	m.pointsAwarded.Observe(float64(totalPoints))
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrapeMetrics(server *Server) string {
+	recorder := httptest.NewRecorder()
+	server.metrics.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return string(body)
+}
+
+func TestMetricsAfterReceiptPost(t *testing.T) {
+	server := newTestServer()
+	handler := server.instrument("/receipts/process", server.handleReceiptPost)
+	payload := `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(payload)))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+
+	metrics := scrapeMetrics(server)
+	expected := []string{
+		`receipt_processor_http_requests_total{method="POST",route="/receipts/process",status="200"}`,
+		`receipt_processor_http_request_duration_seconds_count{method="POST",route="/receipts/process",status="200"}`,
+		`receipt_processor_rule_hits_total{rule="retailer_name"}`,
+		`receipt_processor_points_awarded_count`,
+		`receipt_processor_receipts_stored_total`,
+		`receipt_processor_receipts_in_store`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Metrics should contain '%s'", line)
+		}
+	}
+}
+
+func TestMetricsAfterValidationFailure(t *testing.T) {
+	server := newTestServer()
+	handler := server.instrument("/receipts/process", server.handleReceiptPost)
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(`{"retailer": "", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "items": [], "total": "1.00"}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+
+	metrics := scrapeMetrics(server)
+	expected := []string{
+		`receipt_processor_http_requests_total{method="POST",route="/receipts/process",status="400"}`,
+		`receipt_processor_validation_failures_total{field="retailer",reason="empty"}`,
+		`receipt_processor_validation_failures_total{field="total",reason="total_mismatch"}`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Metrics should contain '%s'", line)
+		}
+	}
+}
+
+func TestMetricsLabelDiscountFieldsWithoutIndexes(t *testing.T) {
+	server := newTestServer()
+	handler := server.instrument("/receipts/process", server.handleReceiptPost)
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "items": [{"shortDescription": "Pepsi", "price": "1.25"}], "discounts": [{"description": "A", "amount": "x"}, {"description": "B", "amount": "y"}], "total": "1.25"}`
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+	metrics := scrapeMetrics(server)
+	if !strings.Contains(metrics, `receipt_processor_validation_failures_total{field="amount",reason="invalid_format"} 2`) || strings.Contains(metrics, "discounts[") {
+		t.Errorf("Should count discount problems by field alone ... %s", metrics)
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"receipt-processor/receipt"
+)
+
+const contentTypeJSON = "application/json"
+const contentTypeXML = "application/xml"
+const contentTypeForm = "application/x-www-form-urlencoded"
+
+var rxFormItem = regexp.MustCompile(`^items\[(\d+)\]\.(shortDescription|price|quantity|unitPrice)$`)
+var rxFormDiscount = regexp.MustCompile(`^discounts\[(\d+)\]\.(description|amount|item)$`)
+var formatNames = map[string]string{contentTypeJSON: "JSON", contentTypeXML: "XML", contentTypeForm: "form"}
+
+const maxFormItems = 1000
+
+type processResponse struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	ID      string   `json:"id" xml:"id"`
+}
+
+type pointsResponse struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	Points  int      `json:"points" xml:"points"`
+}
+
+type breakdownResponse struct {
+	XMLName   xml.Name `json:"-" xml:"response"`
+	Breakdown []string `json:"breakdown" xml:"breakdown>entry"`
+}
+
+func requestContentType(request *http.Request) (string, bool) {
+	header := request.Header.Get("Content-Type")
+	if header == "" {
+		return contentTypeJSON, true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case contentTypeJSON:
+		return contentTypeJSON, true
+	case contentTypeXML, "text/xml":
+		return contentTypeXML, true
+	case contentTypeForm:
+		return contentTypeForm, true
+	}
+	return "", false
+}
+
+func responseContentType(request *http.Request) (string, bool) {
+	header := request.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return contentTypeJSON, true
+	}
+
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, accepted{mediaType, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, mediaRange := range ranges {
+		switch mediaRange.mediaType {
+		case contentTypeJSON, "application/*", "*/*":
+			return contentTypeJSON, true
+		case contentTypeXML, "text/xml":
+			return contentTypeXML, true
+		}
+	}
+	return "", false
+}
+
+func writeResponse(writer http.ResponseWriter, contentType string, statusCode int, v interface{}) {
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Add("Vary", "Accept")
+	writer.WriteHeader(statusCode)
+	if contentType == contentTypeXML {
+		writer.Write([]byte(xml.Header))
+		xml.NewEncoder(writer).Encode(v)
+		return
+	}
+	json.NewEncoder(writer).Encode(v)
+}
+
+func decodeReceipt(contentType string, body []byte, strict bool) (receipt.Receipt, error) {
+	decoded, err := decodeReceiptBody(contentType, body, strict)
+	if err != nil {
+		return decoded, err
+	}
+	return decoded, decoded.RejectServerFields()
+}
+
+func decodeReceiptBody(contentType string, body []byte, strict bool) (receipt.Receipt, error) {
+	switch contentType {
+	case contentTypeXML:
+		var decoded receipt.Receipt
+		err := xml.Unmarshal(body, &decoded)
+		if err != nil {
+			return decoded, fmt.Errorf("Error unmarshaling XML: %w", err)
+		}
+		return decoded, nil
+	case contentTypeForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return receipt.Receipt{}, fmt.Errorf("Error parsing form: %w", err)
+		}
+		return decodeReceiptForm(values, strict)
+	}
+	decoded, err := receipt.DecodeJSON(body, strict)
+	if err != nil {
+		if _, ok := err.(*receipt.DecodeError); ok {
+			return decoded, err
+		}
+		return decoded, fmt.Errorf("Error unmarshaling JSON: %w", err)
+	}
+	return decoded, nil
+}
+
+func decodeReceiptForm(values url.Values, strict bool) (receipt.Receipt, error) {
+	decoded := receipt.Receipt{
+		Retailer:     values.Get("retailer"),
+		PurchaseDate: values.Get("purchaseDate"),
+		PurchaseTime: values.Get("purchaseTime"),
+		Subtotal:     values.Get("subtotal"),
+		Tax:          values.Get("tax"),
+		Tip:          values.Get("tip"),
+		Currency:     values.Get("currency"),
+		TimeZone:     values.Get("timeZone"),
+		Total:        values.Get("total"),
+	}
+	decodeError := &receipt.DecodeError{}
+	items := make(map[int]*receipt.Item)
+	maxIndex := -1
+	discounts := make(map[int]*receipt.Discount)
+	maxDiscount := -1
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(values[key]) > 1 && strict {
+			decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: key, Message: "duplicate key"})
+		}
+		switch key {
+		case "retailer", "purchaseDate", "purchaseTime", "subtotal", "tax", "tip", "total", "currency", "timeZone":
+			continue
+		}
+		if match := rxFormDiscount.FindStringSubmatch(key); match != nil {
+			index, err := strconv.Atoi(match[1])
+			if err != nil || index >= maxFormItems {
+				decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: key, Message: fmt.Sprintf("discount index must be less than %d", maxFormItems)})
+				continue
+			}
+			discount, exists := discounts[index]
+			if !exists {
+				discount = &receipt.Discount{}
+				discounts[index] = discount
+			}
+			switch match[2] {
+			case "description":
+				discount.Description = values.Get(key)
+			case "amount":
+				discount.Amount = values.Get(key)
+			default:
+				item, err2 := strconv.Atoi(values.Get(key))
+				if err2 != nil {
+					decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: key, Message: "expected number"})
+					continue
+				}
+				discount.Item = &item
+			}
+			maxDiscount = max(maxDiscount, index)
+			continue
+		}
+		match := rxFormItem.FindStringSubmatch(key)
+		if match == nil {
+			if strict {
+				decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: key, Message: "unknown field"})
+			}
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil || index >= maxFormItems {
+			decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: key, Message: fmt.Sprintf("item index must be less than %d", maxFormItems)})
+			continue
+		}
+		item, exists := items[index]
+		if !exists {
+			item = &receipt.Item{}
+			items[index] = item
+		}
+		switch match[2] {
+		case "shortDescription":
+			item.ShortDescription = values.Get(key)
+		case "price":
+			item.Price = values.Get(key)
+		case "quantity":
+			item.Quantity = values.Get(key)
+		default:
+			item.UnitPrice = values.Get(key)
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+
+	decoded.Items = []receipt.Item{}
+	for index := 0; index <= maxIndex; index++ {
+		item, exists := items[index]
+		if !exists {
+			decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: fmt.Sprintf("items[%d]", index), Message: "missing item"})
+			continue
+		}
+		decoded.Items = append(decoded.Items, *item)
+	}
+	for index := 0; index <= maxDiscount; index++ {
+		discount, exists := discounts[index]
+		if !exists {
+			decodeError.Problems = append(decodeError.Problems, receipt.DecodeProblem{Path: fmt.Sprintf("discounts[%d]", index), Message: "missing discount"})
+			continue
+		}
+		decoded.Discounts = append(decoded.Discounts, *discount)
+	}
+	if len(decodeError.Problems) > 0 {
+		return decoded, decodeError
+	}
+	return decoded, nil
+}
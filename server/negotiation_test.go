@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"receipt-processor/receipt"
+)
+
+const receiptXML = `<?xml version="1.0" encoding="UTF-8"?>
+<receipt>
+  <retailer>Walgreens</retailer>
+  <purchaseDate>2022-01-02</purchaseDate>
+  <purchaseTime>08:13</purchaseTime>
+  <total>2.65</total>
+  <items>
+    <item><shortDescription>Pepsi - 12-oz</shortDescription><price>1.25</price></item>
+    <item><shortDescription>Dasani</shortDescription><price>1.40</price></item>
+  </items>
+</receipt>`
+
+func serve(handler http.Handler, method string, path string, contentType string, accept string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestProcessXMLReceipt(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/process", "application/xml", "application/xml", receiptXML)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	var processed processResponse
+	err := xml.Unmarshal(recorder.Body.Bytes(), &processed)
+	if err != nil || processed.ID == "" {
+		t.Fatalf("Should have an XML id response ... %s", recorder.Body.String())
+	}
+
+	recorder2 := serve(handler, http.MethodGet, "/receipts/"+processed.ID+"/points", "", "text/xml", "")
+	if recorder2.Header().Get("Content-Type") != "application/xml" {
+		t.Errorf("Should have an XML response not %s", recorder2.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder2.Body.String(), "<response><points>15</points></response>") {
+		t.Errorf("Should have 15 points in XML ... %s", recorder2.Body.String())
+	}
+
+	recorder3 := serve(handler, http.MethodGet, "/receipts/"+processed.ID+"/breakdown", "", "application/xml", "")
+	if !strings.Contains(recorder3.Body.String(), "<entry>15 points total</entry>") {
+		t.Errorf("Should have the breakdown in XML ... %s", recorder3.Body.String())
+	}
+}
+
+func TestProcessFormReceipt(t *testing.T) {
+	handler := newTestServer().Handler()
+	form := url.Values{
+		"retailer":                  {"Walgreens"},
+		"purchaseDate":              {"2022-01-02"},
+		"purchaseTime":              {"08:13"},
+		"total":                     {"2.65"},
+		"items[0].shortDescription": {"Pepsi - 12-oz"},
+		"items[0].price":            {"1.25"},
+		"items[1].shortDescription": {"Dasani"},
+		"items[1].price":            {"1.40"},
+	}
+	recorder := serve(handler, http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+
+	form.Set("items[3].price", "1.00")
+	form.Set("coupon", "SAVE10")
+	recorder2 := serve(handler, http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	if recorder2.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response not %d", recorder2.Code)
+	}
+	for _, expected := range []string{`"path":"coupon","message":"unknown field"`, `"path":"items[2]","message":"missing item"`} {
+		if !strings.Contains(recorder2.Body.String(), expected) {
+			t.Errorf("Should have detail %s ... %s", expected, recorder2.Body.String())
+		}
+	}
+}
+
+func TestProcessFormReceiptWithQuantityAndDiscounts(t *testing.T) {
+	handler := newTestServer().Handler()
+	form := url.Values{
+		"retailer":                  {"Walgreens"},
+		"purchaseDate":              {"2022-01-02"},
+		"purchaseTime":              {"08:13"},
+		"total":                     {"1.75"},
+		"items[0].shortDescription": {"Gatorade"},
+		"items[0].price":            {"2.25"},
+		"items[0].quantity":         {"3"},
+		"items[0].unitPrice":        {"0.75"},
+		"discounts[0].description":  {"Coupon"},
+		"discounts[0].amount":       {"0.50"},
+		"discounts[0].item":         {"0"},
+	}
+	recorder := serve(handler, http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+
+	form.Set("discounts[0].item", "first")
+	form.Set("discounts[2].amount", "0.10")
+	recorder2 := serve(handler, http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	for _, expected := range []string{`"path":"discounts[0].item","message":"expected number"`, `"path":"discounts[1]","message":"missing discount"`} {
+		if !strings.Contains(recorder2.Body.String(), expected) {
+			t.Errorf("Should have detail %s ... %s", expected, recorder2.Body.String())
+		}
+	}
+}
+
+func TestProcessFormReceiptWithTaxAndTip(t *testing.T) {
+	form := url.Values{
+		"retailer":                  {"Corner Cafe"},
+		"purchaseDate":              {"2022-05-06"},
+		"purchaseTime":              {"12:30"},
+		"subtotal":                  {"12.00"},
+		"tax":                       {"0.96"},
+		"tip":                       {"2.00"},
+		"total":                     {"14.97"},
+		"items[0].shortDescription": {"Sandwich"},
+		"items[0].price":            {"12.00"},
+	}
+	recorder := serve(newTestServer().Handler(), http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "= 14.96 != given total (14.97)") {
+		t.Errorf("Should have a total mismatch not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+
+	tolerant := newTestServer(WithValidation(receipt.ValidationOptions{ToleranceCents: 1})).Handler()
+	recorder2 := serve(tolerant, http.MethodPost, "/receipts/process", "application/x-www-form-urlencoded", "", form.Encode())
+	if recorder2.Code != http.StatusOK {
+		t.Errorf("Should allow a one cent difference not %d ... %s", recorder2.Code, recorder2.Body.String())
+	}
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/process", "text/csv", "", "retailer,total\n")
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Should have 415 response not %d", recorder.Code)
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodGet, "/receipts/abc-123/points", "", "text/html", "")
+	if recorder.Code != http.StatusNotAcceptable {
+		t.Errorf("Should have 406 response not %d", recorder.Code)
+	}
+}
+
+func TestResponseContentTypeQuality(t *testing.T) {
+	expected := map[string]string{
+		"":    "application/json",
+		"*/*": "application/json",
+		"application/xml;q=0.9, application/json": "application/json",
+		"application/json;q=0.5, text/xml":        "application/xml",
+		"text/html, application/*;q=0.1":          "application/json",
+	}
+	for accept, contentType := range expected {
+		request := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil)
+		request.Header.Set("Accept", accept)
+		got, ok := responseContentType(request)
+		if !ok || got != contentType {
+			t.Errorf("Accept '%s' should give %s not %s", accept, contentType, got)
+		}
+	}
+}
+
+func TestRejectServerFields(t *testing.T) {
+	handler := newTestServer().Handler()
+	body := `{"retailer":"Target","purchaseDate":"2022-01-01","purchaseTime":"13:01","total":"1.25","items":[{"shortDescription":"Pepsi - 12-oz","price":"1.25"}],"userId":"alice","cap":{"limit":1000,"reason":"none"}}`
+	recorder := serve(handler, http.MethodPost, "/receipts/process", "application/json", "", body)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Should have 400 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	for _, expected := range []string{"userId", "cap", "is set by the server"} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Errorf("Should explain that %s is set by the server ... %s", expected, recorder.Body.String())
+		}
+	}
+
+	xmlBody := strings.Replace(receiptXML, "<retailer>Walgreens</retailer>", "<retailer>Walgreens</retailer><purchasedAt>2022-01-02T08:13:00Z</purchasedAt>", 1)
+	recorder2 := serve(handler, http.MethodPost, "/receipts/process", "application/xml", "", xmlBody)
+	if recorder2.Code != http.StatusBadRequest || !strings.Contains(recorder2.Body.String(), "purchasedAt") {
+		t.Errorf("Should reject purchasedAt in XML not %d ... %s", recorder2.Code, recorder2.Body.String())
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func loadOpenAPIRouter(t *testing.T) routers.Router {
+	doc, err := openapi3.NewLoader().LoadFromData(openapiSpec)
+	if err != nil {
+		t.Fatalf("Could not load openapi.json ... %s", err)
+	}
+	err2 := doc.Validate(context.Background())
+	if err2 != nil {
+		t.Fatalf("openapi.json is not a valid OpenAPI document ... %s", err2)
+	}
+	doc.Servers = nil
+	router, err3 := gorillamux.NewRouter(doc)
+	if err3 != nil {
+		t.Fatalf("Could not build router from openapi.json ... %s", err3)
+	}
+	return router
+}
+
+func checkAgainstSpec(t *testing.T, handler http.Handler, router routers.Router, method string, path string, body []byte) *http.Response {
+	t.Helper()
+	return checkAgainstSpecAs(t, handler, router, method, path, "application/json", body)
+}
+
+func checkAgainstSpecAs(t *testing.T, handler http.Handler, router routers.Router, method string, path string, contentType string, body []byte) *http.Response {
+	t.Helper()
+	request := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	route, pathParams, err := router.FindRoute(request)
+	if err != nil {
+		t.Fatalf("%s %s is not in openapi.json ... %s", method, path, err)
+	}
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{ExcludeRequestBody: body == nil},
+	}
+
+	recorder := httptest.NewRecorder()
+	handlerRequest := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if body != nil {
+		handlerRequest.Header.Set("Content-Type", contentType)
+	}
+	handler.ServeHTTP(recorder, handlerRequest)
+	response := recorder.Result()
+	responseBody, _ := io.ReadAll(response.Body)
+
+	err2 := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 response.StatusCode,
+		Header:                 response.Header,
+		Body:                   io.NopCloser(bytes.NewReader(responseBody)),
+	})
+	if err2 != nil {
+		t.Errorf("(%d) %s %s response does not match openapi.json ... %s\n%s", response.StatusCode, method, path, err2, responseBody)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+	return response
+}
+
+func TestOpenAPISpecServed(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response not %d", recorder.Code)
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), openapiSpec) {
+		t.Errorf("Should serve the embedded openapi.json")
+	}
+}
+
+func TestOpenAPIExamplesMatchSpec(t *testing.T) {
+	router := loadOpenAPIRouter(t)
+	for _, filename := range []string{"../example1.json", "../example2.json", "../example3.json"} {
+		payload, _ := os.ReadFile(filename)
+		request := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(payload))
+		request.Header.Set("Content-Type", "application/json")
+		route, pathParams, _ := router.FindRoute(request)
+		err := openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+			Request:    request,
+			PathParams: pathParams,
+			Route:      route,
+		})
+		if err != nil {
+			t.Errorf("%s does not match the Receipt schema ... %s", filename, err)
+		}
+	}
+}
+
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	router := loadOpenAPIRouter(t)
+	handler := newTestServer().Handler()
+	payload, _ := os.ReadFile("../example2.json")
+
+	response := checkAgainstSpec(t, handler, router, http.MethodPost, "/receipts/process", payload)
+	var processed map[string]string
+	json.NewDecoder(response.Body).Decode(&processed)
+	id := processed["id"]
+
+	checkAgainstSpec(t, handler, router, http.MethodPost, "/receipts/process", []byte(`{}`))
+	checkAgainstSpec(t, handler, router, http.MethodPost, "/receipts/process", []byte(`not json`))
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/"+id+"/points", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/"+id+"/breakdown", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/missing/points", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/missing/breakdown", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts?retailer=Target&limit=10", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts?from=yesterday", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/export?format=csv&rows=item", nil)
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/receipts/export?format=xlsx", nil)
+	checkAgainstSpecAs(t, handler, router, http.MethodPost, "/receipts/parse?submit=true", "text/plain", []byte(walgreensPrintout))
+	checkAgainstSpecAs(t, handler, router, http.MethodPost, "/receipts/parse", "text/plain", []byte("?"))
+	checkAgainstSpecAs(t, handler, router, http.MethodPost, "/receipts/import", "text/csv", []byte(importCSV))
+	checkAgainstSpecAs(t, handler, router, http.MethodPost, "/receipts/import", "text/csv", []byte("retailer\n"))
+	checkAgainstSpec(t, handler, router, http.MethodGet, "/admin/campaigns", nil)
+	checkAgainstSpec(t, handler, router, http.MethodPost, "/rules/simulate", []byte(`{"ruleset": {}, "filter": {}}`))
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"receipt-processor/printout"
+)
+
+const contentTypeText = "text/plain"
+
+type parseResponse struct {
+	printout.Result
+	ID string `json:"id,omitempty"`
+}
+
+func (server *Server) handleReceiptParse(writer http.ResponseWriter, request *http.Request) {
+	header := request.Header.Get("Content-Type")
+	if header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil || mediaType != contentTypeText {
+			message := fmt.Sprintf("Content-Type %s is not supported, use %s", header, contentTypeText)
+			handleError(writer, request, http.StatusUnsupportedMediaType, message)
+			return
+		}
+	}
+	submit := false
+	if value := request.URL.Query().Get("submit"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			handleError(writer, request, http.StatusBadRequest, fmt.Sprintf("submit must be true or false (%s)", value))
+			return
+		}
+		submit = parsed
+	}
+
+	user, err2 := server.userFrom(request)
+	if submit && err2 != nil {
+		handleError(writer, request, http.StatusUnauthorized, err2.Error())
+		return
+	}
+
+	body, err3 := io.ReadAll(request.Body)
+	if err3 != nil {
+		handleError(writer, request, http.StatusBadRequest, "Could not read request body")
+		return
+	}
+	defer request.Body.Close()
+
+	ctx := request.Context()
+	_, parseSpan := tracer.Start(ctx, "receipt.parse", trace.WithAttributes(attribute.Int("text.bytes", len(body))))
+	result := printout.Parse(string(body))
+	parseSpan.SetAttributes(attribute.Int("receipt.items", len(result.Receipt.Items)), attribute.Float64("confidence.min", result.Confidence.Min()))
+	parseSpan.End()
+
+	response := parseResponse{Result: result}
+	if submit {
+		err4 := result.Receipt.ValidateWith(server.validation)
+		if err4 != nil {
+			server.metrics.recordValidationFailure(err4)
+			message := fmt.Sprintf("Validation errors: %s", err4.Error())
+			handleError(writer, request, http.StatusBadRequest, message)
+			return
+		}
+		id, err5 := server.storeReceipt(ctx, result.Receipt, user)
+		if err5 != nil {
+			message := fmt.Sprintf("Could not store receipt: %s", err5.Error())
+			handleError(writer, request, http.StatusInternalServerError, message)
+			return
+		}
+		setReceiptID(request, id)
+		response.ID = id
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const walgreensPrintout = `WALGREENS
+2022-01-02 08:13
+Pepsi - 12-oz    1.25
+Dasani           1.40
+TOTAL            2.65
+`
+
+func TestParseReceiptText(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/parse", "text/plain; charset=utf-8", "", walgreensPrintout)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	var parsed parseResponse
+	json.Unmarshal(recorder.Body.Bytes(), &parsed)
+	if parsed.Receipt.Retailer != "WALGREENS" || parsed.Receipt.Total != "2.65" || len(parsed.Receipt.Items) != 2 {
+		t.Errorf("Should parse the printout ... %s", recorder.Body.String())
+	}
+	if parsed.ID != "" {
+		t.Errorf("Should not store the receipt without submit")
+	}
+	if parsed.Confidence.Total != 1 || len(parsed.Confidence.Items) != 2 {
+		t.Errorf("Should include confidence scores ... %s", recorder.Body.String())
+	}
+}
+
+func TestParseAndSubmitReceiptText(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := serve(handler, http.MethodPost, "/receipts/parse?submit=true", "", "", walgreensPrintout)
+	var parsed parseResponse
+	json.Unmarshal(recorder.Body.Bytes(), &parsed)
+	if recorder.Code != http.StatusOK || parsed.ID == "" {
+		t.Fatalf("(%d) Should store the receipt ... %s", recorder.Code, recorder.Body.String())
+	}
+	recorder2 := serve(handler, http.MethodGet, "/receipts/"+parsed.ID+"/points", "", "", "")
+	if recorder2.Body.String() != "{\"points\":15}\n" {
+		t.Errorf("Should have 15 points for the parsed receipt ... %s", recorder2.Body.String())
+	}
+
+	recorder3 := serve(handler, http.MethodPost, "/receipts/parse?submit=true", "text/plain", "", "nothing to see here")
+	if recorder3.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response for an invalid parsed receipt not %d", recorder3.Code)
+	}
+	recorder4 := serve(handler, http.MethodPost, "/receipts/parse?submit=maybe", "text/plain", "", walgreensPrintout)
+	if recorder4.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response for a bad submit flag not %d", recorder4.Code)
+	}
+	recorder5 := serve(handler, http.MethodPost, "/receipts/parse", "application/json", "", "{}")
+	if recorder5.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Should have 415 response not %d", recorder5.Code)
+	}
+}
@@ -0,0 +1,390 @@
+// Package server serves the receipt processor HTTP API.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"receipt-processor/caps"
+	"receipt-processor/receipt"
+	"receipt-processor/retailers"
+	"receipt-processor/storage"
+)
+
+type Server struct {
+	store      storage.ReceiptStore
+	ruleset    atomic.Pointer[receipt.Ruleset]
+	draining   atomic.Bool
+	logger     *slog.Logger
+	metrics    *metrics
+	version    string
+	commit     string
+	strictJSON bool
+	validation receipt.ValidationOptions
+	retailers  *retailers.Registry
+	adminToken string
+	userSecret string
+	campaigns  campaigns
+	caps       *caps.Ledger
+	now        func() time.Time
+}
+
+type Option func(*Server)
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(server *Server) {
+		server.logger = logger
+	}
+}
+
+func WithRuleset(ruleset *receipt.Ruleset) Option {
+	return func(server *Server) {
+		server.ruleset.Store(ruleset)
+	}
+}
+
+func WithBuildInfo(version string, commit string) Option {
+	return func(server *Server) {
+		server.version = version
+		server.commit = commit
+	}
+}
+
+func WithStrictJSON(strict bool) Option {
+	return func(server *Server) {
+		server.strictJSON = strict
+	}
+}
+
+func WithValidation(options receipt.ValidationOptions) Option {
+	return func(server *Server) {
+		server.validation = options
+	}
+}
+
+func WithRetailers(registry *retailers.Registry) Option {
+	return func(server *Server) {
+		server.retailers = registry
+	}
+}
+
+func WithCaps(limits caps.Limits) Option {
+	return func(server *Server) {
+		server.caps = caps.NewLedger(limits)
+	}
+}
+
+func New(store storage.ReceiptStore, options ...Option) *Server {
+	server := &Server{
+		store:      store,
+		now:        time.Now,
+		logger:     slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: LogLevel()})),
+		version:    "dev",
+		strictJSON: true,
+	}
+	server.ruleset.Store(receipt.StandardRuleset)
+	for _, option := range options {
+		option(server)
+	}
+	server.metrics = newMetrics(store)
+	return server
+}
+
+func (server *Server) Ruleset() *receipt.Ruleset {
+	return server.ruleset.Load().WithCampaigns(server.campaigns.list())
+}
+
+func (server *Server) SetDraining(draining bool) {
+	server.draining.Store(draining)
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /receipts/process", server.route("/receipts/process", server.handleReceiptPost))
+	mux.HandleFunc("POST /receipts/parse", server.route("/receipts/parse", server.handleReceiptParse))
+	mux.HandleFunc("POST /receipts/import", server.route("/receipts/import", server.handleReceiptImport))
+	mux.HandleFunc("GET /receipts", server.route("/receipts", server.handleListReceipts))
+	mux.HandleFunc("GET /receipts/export", server.route("/receipts/export", server.handleExport))
+	mux.HandleFunc("GET /receipts/{id}/points", server.route("/receipts/{id}/points", server.handleGetPoints))
+	mux.HandleFunc("GET /receipts/{id}/breakdown", server.route("/receipts/{id}/breakdown", server.handleGetBreakdown))
+	mux.HandleFunc("POST /rules/simulate", server.route("/rules/simulate", server.requireAdmin(server.handleSimulate)))
+	mux.HandleFunc("GET /admin/campaigns", server.route("/admin/campaigns", server.requireAdmin(server.handleListCampaigns)))
+	mux.HandleFunc("POST /admin/campaigns", server.route("/admin/campaigns", server.requireAdmin(server.handleCreateCampaign)))
+	mux.HandleFunc("GET /admin/campaigns/{id}", server.route("/admin/campaigns/{id}", server.requireAdmin(server.handleGetCampaign)))
+	mux.HandleFunc("PUT /admin/campaigns/{id}", server.route("/admin/campaigns/{id}", server.requireAdmin(server.handlePutCampaign)))
+	mux.HandleFunc("DELETE /admin/campaigns/{id}", server.route("/admin/campaigns/{id}", server.requireAdmin(server.handleDeleteCampaign)))
+	mux.HandleFunc("GET /healthz", server.instrument("/healthz", server.handleHealthz))
+	mux.HandleFunc("GET /readyz", server.instrument("/readyz", server.handleReadyz))
+	mux.HandleFunc("GET /version", server.instrument("/version", server.handleVersion))
+	mux.HandleFunc("GET /openapi.json", server.instrument("/openapi.json", handleOpenAPI))
+	mux.Handle("GET /metrics", server.metrics.handler)
+
+	unmatched := server.route("unmatched", func(writer http.ResponseWriter, request *http.Request) {
+		handleUnmatched(mux, writer, request)
+	})
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, pattern := mux.Handler(request)
+		if pattern == "" {
+			unmatched(writer, request)
+			return
+		}
+		mux.ServeHTTP(writer, request)
+	})
+}
+
+func (server *Server) route(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return server.instrument(route, traceRequests(route, server.logRequests(handler)))
+}
+
+func (server *Server) saveReceipt(ctx context.Context, id string, saved receipt.Receipt) error {
+	ctx, span := tracer.Start(ctx, "store.save", trace.WithAttributes(receiptIDAttribute(id)))
+	defer span.End()
+	err := server.store.Save(ctx, id, saved)
+	if err != nil {
+		recordSpanError(span, err)
+	}
+	return err
+}
+
+// prepareReceipt fills in the fields the server sets on a submitted receipt.
+func (server *Server) prepareReceipt(submitted *receipt.Receipt, user string) error {
+	submitted.Normalize()
+	submitted.RetailerID = server.retailers.Match(submitted.Retailer).ID
+	submitted.UserID = strings.TrimSpace(user)
+	submitted.Cap = nil
+	instant, err := submitted.PurchaseInstant(server.validation.TimeZones.For(submitted))
+	if err != nil {
+		return err
+	}
+	submitted.PurchasedAt = instant.Format(time.RFC3339)
+	submitted.Campaigns = server.Ruleset().ActiveCampaigns(submitted)
+	return nil
+}
+
+func (server *Server) storeReceipt(ctx context.Context, submitted receipt.Receipt, user string) (string, error) {
+	err := server.prepareReceipt(&submitted, user)
+	if err != nil {
+		return "", err
+	}
+
+	results := server.Ruleset().Evaluate(ctx, &submitted)
+	submittedAt := server.now()
+	granted := 0
+	if server.caps != nil {
+		points, _ := receipt.SummarizeRules(results)
+		var reason string
+		granted, reason = server.caps.Award(submitted.UserID, submitted.RetailerID, submittedAt, points)
+		if granted == points {
+			reason = receipt.AwardedReason
+		}
+		submitted.Cap = &receipt.PointsCap{Limit: granted, Reason: reason}
+		results = submitted.Cap.Apply(results)
+	}
+	id := uuid.New().String()
+	err2 := server.saveReceipt(ctx, id, submitted)
+	if err2 != nil {
+		if server.caps != nil {
+			server.caps.Refund(submitted.UserID, submitted.RetailerID, submittedAt, granted)
+		}
+		return "", err2
+	}
+	server.metrics.receiptsStoredTotal.Inc()
+	server.metrics.recordScoring(results)
+	return id, nil
+}
+
+func (server *Server) loadReceipt(ctx context.Context, id string) (receipt.Receipt, bool, error) {
+	ctx, span := tracer.Start(ctx, "store.get", trace.WithAttributes(receiptIDAttribute(id)))
+	defer span.End()
+	found, exists, err := server.store.Get(ctx, id)
+	if err != nil {
+		recordSpanError(span, err)
+	}
+	span.SetAttributes(attribute.Bool("receipt.found", exists))
+	return found, exists, err
+}
+
+func handleError(writer http.ResponseWriter, request *http.Request, statusCode int, message string) {
+	handleErrorDetails(writer, request, statusCode, message, nil)
+}
+
+func handleErrorDetails(writer http.ResponseWriter, request *http.Request, statusCode int, message string, details interface{}) {
+	body := map[string]interface{}{"error": message, "request_id": requestIDFrom(request.Context())}
+	if details != nil {
+		body["details"] = details
+	}
+	writeJSON(writer, statusCode, body)
+	setRequestError(request, message)
+}
+
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (recorder *headerRecorder) Header() http.Header {
+	return recorder.header
+}
+
+func (recorder *headerRecorder) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (recorder *headerRecorder) WriteHeader(statusCode int) {
+	recorder.status = statusCode
+}
+
+func handleUnmatched(mux *http.ServeMux, writer http.ResponseWriter, request *http.Request) {
+	recorder := &headerRecorder{header: http.Header{}, status: http.StatusNotFound}
+	mux.ServeHTTP(recorder, request)
+	allow := recorder.header.Get("Allow")
+	if recorder.status == http.StatusMethodNotAllowed && allow != "" {
+		writer.Header().Set("Allow", allow)
+		message := fmt.Sprintf("Only %s is allowed", strings.Join(strings.Split(allow, ", "), " or "))
+		handleError(writer, request, http.StatusMethodNotAllowed, message)
+		return
+	}
+	message := fmt.Sprintf("%s %s not found", request.Method, request.URL.Path)
+	handleError(writer, request, http.StatusNotFound, message)
+}
+
+func handleNotAcceptable(writer http.ResponseWriter, request *http.Request) {
+	message := fmt.Sprintf("Accept %s is not supported, use %s or %s", request.Header.Get("Accept"), contentTypeJSON, contentTypeXML)
+	handleError(writer, request, http.StatusNotAcceptable, message)
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	json.NewEncoder(writer).Encode(v)
+}
+
+func (server *Server) handleReceiptPost(writer http.ResponseWriter, request *http.Request) {
+	contentType, supported := requestContentType(request)
+	if !supported {
+		message := fmt.Sprintf("Content-Type %s is not supported, use %s, %s or %s", request.Header.Get("Content-Type"), contentTypeJSON, contentTypeXML, contentTypeForm)
+		handleError(writer, request, http.StatusUnsupportedMediaType, message)
+		return
+	}
+	responseType, acceptable := responseContentType(request)
+	if !acceptable {
+		handleNotAcceptable(writer, request)
+		return
+	}
+
+	user, err := server.userFrom(request)
+	if err != nil {
+		handleError(writer, request, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ctx := request.Context()
+	_, decodeSpan := tracer.Start(ctx, "receipt.decode", trace.WithAttributes(attribute.String("content.type", contentType)))
+	body, err2 := ioutil.ReadAll(request.Body)
+	if err2 != nil {
+		recordSpanError(decodeSpan, err2)
+		decodeSpan.End()
+		message := "Could not read request body"
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	defer request.Body.Close()
+
+	submitted, err3 := decodeReceipt(contentType, body, server.strictJSON)
+	if err3 != nil {
+		recordSpanError(decodeSpan, err3)
+		decodeSpan.End()
+		var decodeError *receipt.DecodeError
+		if errors.As(err3, &decodeError) {
+			message := fmt.Sprintf("Invalid %s: %s", formatNames[contentType], decodeError.Error())
+			handleErrorDetails(writer, request, http.StatusBadRequest, message, decodeError.Problems)
+			return
+		}
+		handleError(writer, request, http.StatusBadRequest, err3.Error())
+		return
+	}
+	decodeSpan.SetAttributes(attribute.Int("receipt.items", len(submitted.Items)))
+	decodeSpan.End()
+
+	_, validateSpan := tracer.Start(ctx, "receipt.validate")
+	err4 := submitted.ValidateWith(server.validation)
+	if err4 != nil {
+		recordSpanError(validateSpan, err4)
+		validateSpan.End()
+		server.metrics.recordValidationFailure(err4)
+		message := fmt.Sprintf("Validation errors: %s", err4.Error())
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	validateSpan.End()
+
+	id, err5 := server.storeReceipt(ctx, submitted, user)
+	if err5 != nil {
+		message := fmt.Sprintf("Could not store receipt: %s", err5.Error())
+		handleError(writer, request, http.StatusInternalServerError, message)
+		return
+	}
+	setReceiptID(request, id)
+
+	writeResponse(writer, responseType, http.StatusOK, processResponse{ID: id})
+}
+
+func (server *Server) handleGetPoints(writer http.ResponseWriter, request *http.Request) {
+	responseType, acceptable := responseContentType(request)
+	if !acceptable {
+		handleNotAcceptable(writer, request)
+		return
+	}
+	id := request.PathValue("id")
+	setReceiptID(request, id)
+	found, exists, err := server.loadReceipt(request.Context(), id)
+	if err != nil {
+		message := fmt.Sprintf("Could not load receipt %s: %s", id, err.Error())
+		handleError(writer, request, http.StatusInternalServerError, message)
+		return
+	}
+	if !exists {
+		message := fmt.Sprintf("receipt %s not found", id)
+		handleError(writer, request, http.StatusNotFound, message)
+		return
+	}
+	points, _ := receipt.SummarizeRules(server.Ruleset().Evaluate(request.Context(), &found))
+	writeResponse(writer, responseType, http.StatusOK, pointsResponse{Points: points})
+}
+
+func (server *Server) handleGetBreakdown(writer http.ResponseWriter, request *http.Request) {
+	responseType, acceptable := responseContentType(request)
+	if !acceptable {
+		handleNotAcceptable(writer, request)
+		return
+	}
+	id := request.PathValue("id")
+	setReceiptID(request, id)
+	found, exists, err := server.loadReceipt(request.Context(), id)
+	if err != nil {
+		message := fmt.Sprintf("Could not load receipt %s: %s", id, err.Error())
+		handleError(writer, request, http.StatusInternalServerError, message)
+		return
+	}
+	if !exists {
+		message := fmt.Sprintf("receipt %s not found", id)
+		handleError(writer, request, http.StatusNotFound, message)
+		return
+	}
+	points, breakdown := receipt.SummarizeRules(server.Ruleset().Evaluate(request.Context(), &found))
+	breakdown = append(breakdown, fmt.Sprintf("%d points total", points))
+	writeResponse(writer, responseType, http.StatusOK, breakdownResponse{Breakdown: breakdown})
+}
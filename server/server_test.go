@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"receipt-processor/caps"
+	"receipt-processor/receipt"
+	"receipt-processor/storage"
+)
+
+func newTestServer(options ...Option) *Server {
+	options = append([]Option{WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))}, options...)
+	return New(storage.NewMemoryStore(), options...)
+}
+
+func postExample(t *testing.T, handler http.Handler, filename string) string {
+	t.Helper()
+	payload, _ := os.ReadFile(filename)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(string(payload))))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should have 200 response for %s not %d ... %s", filename, recorder.Code, recorder.Body.String())
+	}
+	id := strings.Split(recorder.Body.String(), `"`)[3]
+	return id
+}
+
+func TestGetPointsForExamples(t *testing.T) {
+	handler := newTestServer().Handler()
+	expected := map[string]string{
+		"../example1.json": `{"points":15}`,
+		"../example2.json": `{"points":28}`,
+		"../example3.json": `{"points":149}`,
+	}
+	for filename, body := range expected {
+		id := postExample(t, handler, filename)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/points", nil))
+		if strings.TrimSpace(recorder.Body.String()) != body {
+			t.Errorf("Should have %s for %s not %s", body, filename, recorder.Body.String())
+		}
+	}
+}
+
+func TestGetBreakdownForExample(t *testing.T) {
+	handler := newTestServer().Handler()
+	id := postExample(t, handler, "../example1.json")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/breakdown", nil))
+	if !strings.Contains(recorder.Body.String(), "15 points total") {
+		t.Errorf("Breakdown should end with the total ... %s", recorder.Body.String())
+	}
+}
+
+func TestStorePurchaseInstant(t *testing.T) {
+	zones := receipt.TimeZones{Default: "America/Chicago", Retailers: map[string]string{"Target": "America/New_York"}}
+	handler := newTestServer(WithValidation(receipt.ValidationOptions{TimeZones: zones})).Handler()
+	postExample(t, handler, "../example1.json")
+	postExample(t, handler, "../example2.json")
+
+	recorder := serve(handler, http.MethodGet, "/receipts/export?format=jsonl", "", "", "")
+	for _, expected := range []string{`"purchasedAt":"2022-01-01T13:01:00-05:00"`, `"purchasedAt":"2022-01-02T08:13:00-06:00"`} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Errorf("Should store the instant in the store's zone %s ... %s", expected, recorder.Body.String())
+		}
+	}
+}
+
+func postAsUser(t *testing.T, handler http.Handler, filename string, user string) string {
+	t.Helper()
+	payload, _ := os.ReadFile(filename)
+	request := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(string(payload)))
+	request.Header.Set("X-User-ID", user)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have 200 response for %s not %d ... %s", filename, recorder.Code, recorder.Body.String())
+		return ""
+	}
+	return strings.Split(recorder.Body.String(), `"`)[3]
+}
+
+func TestPointCaps(t *testing.T) {
+	handler := newTestServer(WithCaps(caps.Limits{UserDay: 40})).Handler()
+	first := postAsUser(t, handler, "../example2.json", "alice")
+	second := postAsUser(t, handler, "../example2.json", "alice")
+	other := postAsUser(t, handler, "../example2.json", "bob")
+
+	expected := map[string]string{first: `{"points":28}`, second: `{"points":12}`, other: `{"points":28}`}
+	for id, body := range expected {
+		recorder := serve(handler, http.MethodGet, "/receipts/"+id+"/points", "", "", "")
+		if strings.TrimSpace(recorder.Body.String()) != body {
+			t.Errorf("Should have %s not %s", body, recorder.Body.String())
+		}
+	}
+	breakdown := serve(handler, http.MethodGet, "/receipts/"+second+"/breakdown", "", "", "")
+	if !strings.Contains(breakdown.Body.String(), "-16 points capped by the daily limit of 40 points for user alice") {
+		t.Errorf("Should show the capped points ... %s", breakdown.Body.String())
+	}
+}
+
+func TestPointCapsHoldWhenRulesChange(t *testing.T) {
+	testServer := newTestServer(WithCaps(caps.Limits{UserDay: 30}))
+	handler := testServer.Handler()
+	id := postAsUser(t, handler, "../example2.json", "alice")
+
+	bonuses := &receipt.Bonuses{Rules: []receipt.BonusRule{{ID: "target", Retailer: "Target", Points: 500}}}
+	bonuses.Compile()
+	testServer.ruleset.Store(receipt.StandardRuleset.WithBonuses(bonuses))
+	recorder := serve(handler, http.MethodGet, "/receipts/"+id+"/points", "", "", "")
+	if strings.TrimSpace(recorder.Body.String()) != `{"points":28}` {
+		t.Errorf("Should keep the points charged against the caps ... %s", recorder.Body.String())
+	}
+	breakdown := serve(handler, http.MethodGet, "/receipts/"+id+"/breakdown", "", "", "")
+	if !strings.Contains(breakdown.Body.String(), "-500 points capped by the points awarded when the receipt was stored") {
+		t.Errorf("Should explain the held back points ... %s", breakdown.Body.String())
+	}
+}
+
+func TestPointCapsUnderConcurrentSubmissions(t *testing.T) {
+	testServer := newTestServer(WithCaps(caps.Limits{UserDay: 100}))
+	handler := testServer.Handler()
+	ids := make(chan string, 20)
+	var wait sync.WaitGroup
+	for range 20 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			ids <- postAsUser(t, handler, "../example2.json", "alice")
+		}()
+	}
+	wait.Wait()
+	close(ids)
+
+	total := 0
+	for id := range ids {
+		stored, _, _ := testServer.store.Get(context.Background(), id)
+		points, _ := receipt.SummarizeRules(testServer.Ruleset().Evaluate(context.Background(), &stored))
+		total += points
+	}
+	if total != 100 {
+		t.Errorf("Should award exactly the daily limit not %d", total)
+	}
+}
+
+func TestGetPointsNotFound(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/missing/points", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Should have 404 response not %d", recorder.Code)
+	}
+}
+
+func TestUnknownRouteNotFound(t *testing.T) {
+	handler := newTestServer().Handler()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts/bad", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Should have 404 response not %d", recorder.Code)
+	}
+	if recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Should have a JSON body not %s", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), `"error":"GET /receipts/bad not found"`) {
+		t.Errorf("Should have an error field ... %s", recorder.Body.String())
+	}
+}
+
+func TestWrongMethodNotAllowed(t *testing.T) {
+	handler := newTestServer().Handler()
+	expected := []struct {
+		method  string
+		path    string
+		allow   string
+		message string
+	}{
+		{http.MethodGet, "/receipts/process", "POST", "Only POST is allowed"},
+		{http.MethodPost, "/receipts/abc-123/points", "GET, HEAD", "Only GET or HEAD is allowed"},
+		{http.MethodDelete, "/receipts/abc-123/breakdown", "GET, HEAD", "Only GET or HEAD is allowed"},
+	}
+	for _, test := range expected {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s should have 405 response not %d", test.method, test.path, recorder.Code)
+		}
+		if recorder.Header().Get("Allow") != test.allow {
+			t.Errorf("%s %s should have Allow '%s' not '%s'", test.method, test.path, test.allow, recorder.Header().Get("Allow"))
+		}
+		if !strings.Contains(recorder.Body.String(), test.message) {
+			t.Errorf("%s %s should have error '%s' ... %s", test.method, test.path, test.message, recorder.Body.String())
+		}
+	}
+}
+
+func TestStrictJSONDetails(t *testing.T) {
+	handler := newTestServer().Handler()
+	payload := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "0.00", "items": [], "extra": true}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(payload)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Should have 400 response not %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `"details":[{"path":"extra","message":"unknown field"}]`) {
+		t.Errorf("Should have details for the unknown field ... %s", recorder.Body.String())
+	}
+}
+
+func TestLenientJSON(t *testing.T) {
+	handler := newTestServer(WithStrictJSON(false)).Handler()
+	payload := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00",
+		"items": [{"shortDescription": "Pepsi", "price": "1.00"}], "extra": true}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(payload)))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Lenient mode should have 200 response not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+}
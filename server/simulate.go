@@ -0,0 +1,381 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+
+	"receipt-processor/receipt"
+	"receipt-processor/storage"
+)
+
+type candidateRuleset struct {
+	Base        string                   `json:"base,omitempty"`
+	Amount      string                   `json:"amount,omitempty"`
+	Disable     []string                 `json:"disable,omitempty"`
+	Expressions *receipt.ExpressionRules `json:"expressions,omitempty"`
+	Bonuses     *receipt.Bonuses         `json:"bonuses,omitempty"`
+}
+
+type simulationFilter struct {
+	Retailer   string `json:"retailer,omitempty"`
+	RetailerID string `json:"retailerId,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+}
+
+type simulationRequest struct {
+	Ruleset candidateRuleset  `json:"ruleset"`
+	Receipt json.RawMessage   `json:"receipt,omitempty"`
+	Filter  *simulationFilter `json:"filter,omitempty"`
+	Limit   *int              `json:"limit,omitempty"`
+}
+
+type simulatedReceipt struct {
+	ID           string   `json:"id,omitempty"`
+	Retailer     string   `json:"retailer"`
+	RetailerID   string   `json:"retailerId"`
+	PurchaseDate string   `json:"purchaseDate"`
+	Total        string   `json:"total"`
+	OldPoints    int      `json:"oldPoints"`
+	IssuedPoints int      `json:"issuedPoints"`
+	NewPoints    int      `json:"newPoints"`
+	Delta        int      `json:"delta"`
+	OldBreakdown []string `json:"oldBreakdown,omitempty"`
+	NewBreakdown []string `json:"newBreakdown,omitempty"`
+}
+
+type distribution struct {
+	Min    float64 `json:"min"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+type ruleImpact struct {
+	Rule      string `json:"rule"`
+	OldPoints int    `json:"oldPoints"`
+	NewPoints int    `json:"newPoints"`
+	Delta     int    `json:"delta"`
+	Receipts  int    `json:"receipts"`
+}
+
+type simulationSummary struct {
+	Receipts    int          `json:"receipts"`
+	OldTotal    int          `json:"oldTotal"`
+	IssuedTotal int          `json:"issuedTotal"`
+	NewTotal    int          `json:"newTotal"`
+	Delta       int          `json:"delta"`
+	Increased   int          `json:"increased"`
+	Decreased   int          `json:"decreased"`
+	Unchanged   int          `json:"unchanged"`
+	OldPoints   distribution `json:"oldPoints"`
+	NewPoints   distribution `json:"newPoints"`
+	Shift       distribution `json:"shift"`
+	Rules       []ruleImpact `json:"rules"`
+}
+
+type simulationResponse struct {
+	OldRuleset string             `json:"oldRuleset"`
+	NewRuleset string             `json:"newRuleset"`
+	Summary    simulationSummary  `json:"summary"`
+	Receipts   []simulatedReceipt `json:"receipts"`
+}
+
+// simulation scores receipts with the current and the candidate ruleset. Both
+// score before point caps, since the caps depend on what else was awarded, and
+// the points issued under the cap stored with each receipt are kept apart.
+type simulation struct {
+	current   *receipt.Ruleset
+	candidate *receipt.Ruleset
+	receipts  []simulatedReceipt
+	oldPoints []int
+	newPoints []int
+	rules     map[string]*ruleImpact
+}
+
+// build makes the candidate ruleset from the current one. A base swaps in the
+// rules of another built-in ruleset, and bonuses replace the current ones.
+func (candidate candidateRuleset) build(current *receipt.Ruleset) (*receipt.Ruleset, error) {
+	ruleset := current
+	if candidate.Base != "" {
+		base, found := receipt.Rulesets[candidate.Base]
+		if !found {
+			return nil, fmt.Errorf("unknown base ruleset %s", candidate.Base)
+		}
+		ruleset = current.Rebased(base)
+	}
+	if candidate.Amount != "" {
+		var err error
+		ruleset, err = ruleset.WithAmount(candidate.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(candidate.Disable) > 0 {
+		var err2 error
+		ruleset, err2 = ruleset.Without(candidate.Disable...)
+		if err2 != nil {
+			return nil, err2
+		}
+	}
+	if candidate.Expressions != nil {
+		err3 := candidate.Expressions.Compile()
+		if err3 != nil {
+			return nil, err3
+		}
+		var err4 error
+		ruleset, err4 = ruleset.WithExpressions(candidate.Expressions)
+		if err4 != nil {
+			return nil, err4
+		}
+	}
+	if candidate.Bonuses != nil {
+		err5 := candidate.Bonuses.Compile()
+		if err5 != nil {
+			return nil, err5
+		}
+		ruleset = ruleset.WithBonuses(candidate.Bonuses)
+	}
+	return ruleset, nil
+}
+
+func pointsByRule(results []receipt.RuleResult) map[string]int {
+	points := make(map[string]int)
+	for _, result := range results {
+		points[result.Rule] += result.Points
+	}
+	return points
+}
+
+func (sim *simulation) score(ctx context.Context, id string, scored receipt.Receipt, breakdown bool) {
+	stored := scored.Cap
+	scored.Cap = nil
+	oldResults := sim.current.Evaluate(ctx, &scored)
+	newResults := sim.candidate.Evaluate(ctx, &scored)
+	oldPoints, oldBreakdown := receipt.SummarizeRules(oldResults)
+	issuedPoints, _ := receipt.SummarizeRules(stored.Apply(oldResults))
+	newPoints, newBreakdown := receipt.SummarizeRules(newResults)
+	simulated := simulatedReceipt{
+		ID:           id,
+		Retailer:     scored.Retailer,
+		RetailerID:   scored.RetailerID,
+		PurchaseDate: scored.PurchaseDate,
+		Total:        scored.Total,
+		OldPoints:    oldPoints,
+		IssuedPoints: issuedPoints,
+		NewPoints:    newPoints,
+		Delta:        newPoints - oldPoints,
+	}
+	if breakdown {
+		simulated.OldBreakdown, simulated.NewBreakdown = oldBreakdown, newBreakdown
+	}
+	sim.receipts = append(sim.receipts, simulated)
+	sim.oldPoints = append(sim.oldPoints, oldPoints)
+	sim.newPoints = append(sim.newPoints, newPoints)
+
+	oldByRule, newByRule := pointsByRule(oldResults), pointsByRule(newResults)
+	for name := range newByRule {
+		if _, exists := oldByRule[name]; !exists {
+			oldByRule[name] = 0
+		}
+	}
+	for name, points := range oldByRule {
+		impact := sim.rules[name]
+		if impact == nil {
+			impact = &ruleImpact{Rule: name}
+			sim.rules[name] = impact
+		}
+		impact.OldPoints += points
+		impact.NewPoints += newByRule[name]
+		if newByRule[name] != points {
+			impact.Receipts++
+		}
+	}
+}
+
+// percentile uses the nearest rank of sorted points.
+func percentile(sorted []int, fraction float64) float64 {
+	rank := int(math.Ceil(fraction*float64(len(sorted)))) - 1
+	return float64(sorted[max(rank, 0)])
+}
+
+func distributionOf(points []int) distribution {
+	if len(points) == 0 {
+		return distribution{}
+	}
+	sorted := slices.Clone(points)
+	slices.Sort(sorted)
+	sum := 0
+	for _, value := range sorted {
+		sum += value
+	}
+	return distribution{
+		Min:    float64(sorted[0]),
+		P25:    percentile(sorted, 0.25),
+		Median: percentile(sorted, 0.5),
+		P75:    percentile(sorted, 0.75),
+		P90:    percentile(sorted, 0.9),
+		Max:    float64(sorted[len(sorted)-1]),
+		Mean:   math.Round(float64(sum)/float64(len(sorted))*100) / 100,
+	}
+}
+
+func (sim *simulation) response(limit int) simulationResponse {
+	summary := simulationSummary{
+		Receipts:  len(sim.receipts),
+		OldPoints: distributionOf(sim.oldPoints),
+		NewPoints: distributionOf(sim.newPoints),
+		Rules:     []ruleImpact{},
+	}
+	for _, simulated := range sim.receipts {
+		summary.OldTotal += simulated.OldPoints
+		summary.IssuedTotal += simulated.IssuedPoints
+		summary.NewTotal += simulated.NewPoints
+		switch {
+		case simulated.Delta > 0:
+			summary.Increased++
+		case simulated.Delta < 0:
+			summary.Decreased++
+		default:
+			summary.Unchanged++
+		}
+	}
+	summary.Delta = summary.NewTotal - summary.OldTotal
+	summary.Shift = distribution{
+		Min:    summary.NewPoints.Min - summary.OldPoints.Min,
+		P25:    summary.NewPoints.P25 - summary.OldPoints.P25,
+		Median: summary.NewPoints.Median - summary.OldPoints.Median,
+		P75:    summary.NewPoints.P75 - summary.OldPoints.P75,
+		P90:    summary.NewPoints.P90 - summary.OldPoints.P90,
+		Max:    summary.NewPoints.Max - summary.OldPoints.Max,
+		Mean:   math.Round((summary.NewPoints.Mean-summary.OldPoints.Mean)*100) / 100,
+	}
+	for _, impact := range sim.rules {
+		impact.Delta = impact.NewPoints - impact.OldPoints
+		if impact.Receipts > 0 {
+			summary.Rules = append(summary.Rules, *impact)
+		}
+	}
+	slices.SortFunc(summary.Rules, func(a, b ruleImpact) int {
+		if order := abs(b.Delta) - abs(a.Delta); order != 0 {
+			return order
+		}
+		if order := b.Receipts - a.Receipts; order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Rule, b.Rule)
+	})
+
+	receipts := slices.Clone(sim.receipts)
+	slices.SortStableFunc(receipts, func(a, b simulatedReceipt) int {
+		return abs(b.Delta) - abs(a.Delta)
+	})
+	if len(receipts) > limit {
+		receipts = receipts[:limit]
+	}
+	if receipts == nil {
+		receipts = []simulatedReceipt{}
+	}
+	return simulationResponse{
+		OldRuleset: sim.current.Version,
+		NewRuleset: sim.candidate.Version,
+		Summary:    summary,
+		Receipts:   receipts,
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func (server *Server) handleSimulate(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+	var simulate simulationRequest
+	decoder := json.NewDecoder(request.Body)
+	if server.strictJSON {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(&simulate)
+	if err != nil {
+		message := fmt.Sprintf("Invalid simulation: %s", err.Error())
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	if (simulate.Receipt == nil) == (simulate.Filter == nil) {
+		handleError(writer, request, http.StatusBadRequest, "Invalid simulation: give either a receipt or a filter")
+		return
+	}
+	limit := defaultListLimit
+	if simulate.Limit != nil {
+		if *simulate.Limit < 0 || *simulate.Limit > maxListLimit {
+			message := fmt.Sprintf("limit must be a whole number from 0 to %d (%d)", maxListLimit, *simulate.Limit)
+			handleError(writer, request, http.StatusBadRequest, message)
+			return
+		}
+		limit = *simulate.Limit
+	}
+
+	current := server.Ruleset()
+	candidate, err2 := simulate.Ruleset.build(current)
+	if err2 != nil {
+		message := fmt.Sprintf("Invalid ruleset: %s", err2.Error())
+		handleError(writer, request, http.StatusBadRequest, message)
+		return
+	}
+	sim := &simulation{current: current, candidate: candidate, rules: make(map[string]*ruleImpact)}
+	ctx := request.Context()
+
+	if simulate.Receipt != nil {
+		submitted, err3 := decodeReceipt(contentTypeJSON, simulate.Receipt, server.strictJSON)
+		if err3 != nil {
+			var decodeError *receipt.DecodeError
+			if errors.As(err3, &decodeError) {
+				message := fmt.Sprintf("Invalid JSON: %s", decodeError.Error())
+				handleErrorDetails(writer, request, http.StatusBadRequest, message, decodeError.Problems)
+				return
+			}
+			handleError(writer, request, http.StatusBadRequest, err3.Error())
+			return
+		}
+		err4 := submitted.ValidateWith(server.validation)
+		if err4 == nil {
+			err4 = server.prepareReceipt(&submitted, "")
+		}
+		if err4 != nil {
+			message := fmt.Sprintf("Validation errors: %s", err4.Error())
+			handleError(writer, request, http.StatusBadRequest, message)
+			return
+		}
+		sim.score(ctx, "", submitted, true)
+		writeJSON(writer, http.StatusOK, sim.response(limit))
+		return
+	}
+
+	filter, err5 := server.resolveFilter(storage.Filter(*simulate.Filter))
+	if err5 != nil {
+		handleError(writer, request, http.StatusBadRequest, err5.Error())
+		return
+	}
+	err6 := server.store.List(ctx, filter, func(id string, stored receipt.Receipt) error {
+		sim.score(ctx, id, stored, false)
+		return nil
+	})
+	if err6 != nil {
+		message := fmt.Sprintf("Could not list receipts: %s", err6.Error())
+		handleError(writer, request, http.StatusInternalServerError, message)
+		return
+	}
+	writeJSON(writer, http.StatusOK, sim.response(limit))
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"receipt-processor/caps"
+)
+
+func simulate(t *testing.T, handler http.Handler, body string) simulationResponse {
+	t.Helper()
+	recorder := adminRequest(handler, http.MethodPost, "/rules/simulate", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Should simulate not %d ... %s", recorder.Code, recorder.Body.String())
+	}
+	var response simulationResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Should return JSON ... %v", err)
+	}
+	return response
+}
+
+func TestSimulateInlineReceipt(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	payload, _ := os.ReadFile("../example2.json")
+	response := simulate(t, handler, `{"ruleset": {"disable": ["retailer_name"], "bonuses": {"rules": [{"id": "cheese", "item": "cheese", "points": 5}]}}, "receipt": `+string(payload)+`}`)
+
+	if response.OldRuleset != "standard-1" || response.Summary.Receipts != 1 || len(response.Receipts) != 1 {
+		t.Fatalf("Should score the one receipt ... %+v", response)
+	}
+	simulated := response.Receipts[0]
+	if simulated.ID != "" || simulated.OldPoints != 28 || simulated.NewPoints != 32 || simulated.Delta != 4 {
+		t.Errorf("Should lose 6 retailer points and gain 10 bonus points ... %+v", simulated)
+	}
+	if !strings.Contains(strings.Join(simulated.NewBreakdown, "\n"), "5 bonus points for cheese") {
+		t.Errorf("Should give the new breakdown of an inline receipt ... %v", simulated.NewBreakdown)
+	}
+	rules := response.Summary.Rules
+	if len(rules) != 2 || rules[0].Rule != "bonus_cheese" || rules[0].Delta != 10 || rules[1].Rule != "retailer_name" || rules[1].Delta != -6 {
+		t.Errorf("Should rank the rules by how much they changed ... %+v", rules)
+	}
+
+	count := httptest.NewRecorder()
+	handler.ServeHTTP(count, httptest.NewRequest(http.MethodGet, "/receipts", nil))
+	if count.Body.String() != "{\"receipts\":[]}\n" {
+		t.Errorf("Should not store the inline receipt ... %s", count.Body.String())
+	}
+}
+
+func TestSimulateStoredReceipts(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	for _, filename := range []string{"../example1.json", "../example2.json", "../example3.json"} {
+		postExample(t, handler, filename)
+	}
+
+	response := simulate(t, handler, `{"ruleset": {"disable": ["purchase_time"]}, "filter": {}, "limit": 2}`)
+	summary := response.Summary
+	if summary.Receipts != 3 || summary.Delta != summary.NewTotal-summary.OldTotal || summary.Decreased+summary.Unchanged != 3 {
+		t.Errorf("Should summarize every stored receipt ... %+v", summary)
+	}
+	if summary.OldPoints.Max < summary.OldPoints.Median || summary.Shift.P75 != -10 || summary.Shift.Mean != -3.33 {
+		t.Errorf("Should describe the distributions ... %+v", summary)
+	}
+	if len(response.Receipts) != 2 || response.Receipts[0].Delta > response.Receipts[1].Delta {
+		t.Errorf("Should list the most changed receipts up to the limit ... %+v", response.Receipts)
+	}
+	for _, simulated := range response.Receipts {
+		if simulated.ID == "" || simulated.NewBreakdown != nil {
+			t.Errorf("Should list stored receipts by id without breakdowns ... %+v", simulated)
+		}
+	}
+
+	target := simulate(t, handler, `{"ruleset": {"base": "quantity-1", "amount": "subtotal"}, "filter": {"retailer": "target", "from": "2022-01-01", "to": "2022-01-31"}}`)
+	if target.Summary.Receipts != 1 || target.NewRuleset != "quantity-1+subtotal" {
+		t.Errorf("Should filter the stored receipts and build the candidate ruleset ... %+v", target)
+	}
+}
+
+func TestSimulateReportsIssuedPoints(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret"), WithCaps(caps.Limits{UserDay: 30})).Handler()
+	postAsUser(t, handler, "../example2.json", "alice")
+	postAsUser(t, handler, "../example2.json", "alice")
+
+	response := simulate(t, handler, `{"ruleset": {}, "filter": {}}`)
+	summary := response.Summary
+	if summary.OldTotal != 56 || summary.IssuedTotal != 30 || summary.NewTotal != 56 || summary.Unchanged != 2 {
+		t.Errorf("Should compare the uncapped points and report the points issued apart ... %+v", summary)
+	}
+	issued := []int{response.Receipts[0].IssuedPoints, response.Receipts[1].IssuedPoints}
+	slices.Sort(issued)
+	if !slices.Equal(issued, []int{2, 28}) {
+		t.Errorf("Should give the points each receipt was issued under its cap not %v", issued)
+	}
+}
+
+func TestSimulateRejectsBadRequests(t *testing.T) {
+	handler := newTestServer(WithAdminToken("secret")).Handler()
+	payload, _ := os.ReadFile("../example1.json")
+	cases := []struct {
+		body    string
+		message string
+	}{
+		{`{"ruleset": {}}`, "give either a receipt or a filter"},
+		{`{"ruleset": {}, "filter": {}, "receipt": ` + string(payload) + `}`, "give either a receipt or a filter"},
+		{`{"ruleset": {"base": "bogus-1"}, "filter": {}}`, "unknown base ruleset bogus-1"},
+		{`{"ruleset": {"disable": ["retailer"]}, "filter": {}}`, "standard-1 has no rule retailer"},
+		{`{"ruleset": {"expressions": {"rules": [{"name": "half", "expression": "total / 2"}]}}, "filter": {}}`, "gives fractions of points"},
+		{`{"ruleset": {}, "filter": {"from": "January"}}`, "from must be a YYYY-MM-DD date"},
+		{`{"ruleset": {}, "filter": {}, "limit": 5000}`, "limit must be a whole number from 0 to 1000"},
+		{`{"ruleset": {}, "receipt": {"retailer": "Target"}}`, "missing required field"},
+		{`{"ruleset": {}, "receipt": ` + strings.Replace(string(payload), "08:13", "25:13", 1) + `}`, "Validation errors"},
+	}
+	for _, c := range cases {
+		recorder := adminRequest(handler, http.MethodPost, "/rules/simulate", c.body)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), c.message) {
+			t.Errorf("Should reject %s with %q not %d ... %s", c.body, c.message, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	unauthorized := httptest.NewRecorder()
+	handler.ServeHTTP(unauthorized, httptest.NewRequest(http.MethodPost, "/rules/simulate", strings.NewReader(`{"filter": {}}`)))
+	if unauthorized.Code != http.StatusUnauthorized {
+		t.Errorf("Should require the admin token not %d", unauthorized.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var spanRecorder = tracetest.NewSpanRecorder()
+var spanRecorderOnce sync.Once
+
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+func TestTraceReceiptPost(t *testing.T) {
+	recorder := recordSpans()
+	server := newTestServer()
+	handler := traceRequests("/receipts/process", server.handleReceiptPost)
+	payload := `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`
+	request := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(payload))
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), request)
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == traceID {
+			names[span.Name()] = true
+		}
+	}
+	expected := []string{
+		"POST /receipts/process",
+		"receipt.decode",
+		"receipt.validate",
+		"store.save",
+		"receipt.score",
+		"rule retailer_name",
+		"rule item_description",
+		"rule purchase_time",
+	}
+	for _, name := range expected {
+		if !names[name] {
+			t.Errorf("Should have a span named '%s' in trace %s ... %v", name, traceID, names)
+		}
+	}
+}
+
+func TestSetupTracingUnsupportedExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "carrier-pigeon")
+	_, err := SetupTracing(context.Background())
+	if err == nil {
+		t.Errorf("Should have an error for an unsupported exporter")
+	}
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	shutdown, err := SetupTracing(context.Background())
+	if err != nil {
+		t.Errorf("Should not have an error when tracing is disabled ... %s", err)
+	}
+	shutdown(context.Background())
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const userHeader = "X-User-ID"
+
+var errUnsignedUser = errors.New("X-User-ID must be a user id signed with the server's user secret")
+
+// WithUserSecret makes the server accept only user ids signed by SignUserID, so
+// clients cannot pick a new user to get around the per-user point caps.
+func WithUserSecret(secret string) Option {
+	return func(server *Server) {
+		server.userSecret = secret
+	}
+}
+
+// SignUserID gives the X-User-ID value for a user: the id, a dot and the hex
+// HMAC-SHA256 of the id with the secret.
+func SignUserID(secret string, user string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(user))
+	return user + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// userFrom gives the user submitting a request, or an empty id for anonymous
+// requests. Without a user secret the header is trusted as sent.
+func (server *Server) userFrom(request *http.Request) (string, error) {
+	value := strings.TrimSpace(request.Header.Get(userHeader))
+	if value == "" || server.userSecret == "" {
+		return value, nil
+	}
+	index := strings.LastIndex(value, ".")
+	if index <= 0 || !hmac.Equal([]byte(SignUserID(server.userSecret, value[:index])), []byte(value)) {
+		return "", errUnsignedUser
+	}
+	return value[:index], nil
+}
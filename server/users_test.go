@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"receipt-processor/caps"
+)
+
+func TestUserFrom(t *testing.T) {
+	server := newTestServer(WithUserSecret("secret"))
+	cases := []struct {
+		header   string
+		expected string
+		valid    bool
+	}{
+		{"", "", true},
+		{SignUserID("secret", "alice"), "alice", true},
+		{SignUserID("secret", "alice.smith"), "alice.smith", true},
+		{"alice", "", false},
+		{SignUserID("other", "alice"), "", false},
+		{strings.Replace(SignUserID("secret", "alice"), "alice", "bob", 1), "", false},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(http.MethodPost, "/receipts/process", nil)
+		request.Header.Set("X-User-ID", c.header)
+		user, err := server.userFrom(request)
+		if user != c.expected || (err == nil) != c.valid {
+			t.Errorf("Should read %q as %q (%t) not %q ... %v", c.header, c.expected, c.valid, user, err)
+		}
+	}
+
+	trusting := newTestServer()
+	request := httptest.NewRequest(http.MethodPost, "/receipts/process", nil)
+	request.Header.Set("X-User-ID", " alice ")
+	if user, err := trusting.userFrom(request); user != "alice" || err != nil {
+		t.Errorf("Should trust the header without a secret not %q ... %v", user, err)
+	}
+}
+
+func serveAsUser(handler http.Handler, path string, contentType string, body string, user string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("X-User-ID", user)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestSignedUsersCannotRotateAroundCaps(t *testing.T) {
+	handler := newTestServer(WithUserSecret("secret"), WithCaps(caps.Limits{UserDay: 30})).Handler()
+	payload, _ := os.ReadFile("../example2.json")
+	for _, user := range []string{"alice-1", "alice-2"} {
+		recorder := serveAsUser(handler, "/receipts/process", "application/json", string(payload), user)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Should reject the unsigned user %s not %d", user, recorder.Code)
+		}
+	}
+	imported := serveAsUser(handler, "/receipts/import", "text/csv", importCSV, "alice-3")
+	if imported.Code != http.StatusUnauthorized {
+		t.Errorf("Should reject unsigned users on imports too not %d", imported.Code)
+	}
+	signed := serveAsUser(handler, "/receipts/process", "application/json", string(payload), SignUserID("secret", "alice"))
+	if signed.Code != http.StatusOK {
+		t.Errorf("Should accept a signed user not %d ... %s", signed.Code, signed.Body.String())
+	}
+}
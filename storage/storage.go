@@ -0,0 +1,114 @@
+// Package storage holds the receipt stores used by the server.
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"receipt-processor/receipt"
+)
+
+type ReceiptStore interface {
+	Save(ctx context.Context, id string, saved receipt.Receipt) error
+	Get(ctx context.Context, id string) (receipt.Receipt, bool, error)
+	Count(ctx context.Context) (int, error)
+	List(ctx context.Context, filter Filter, each func(id string, stored receipt.Receipt) error) error
+	Ping(ctx context.Context) error
+}
+
+type Filter struct {
+	Retailer   string
+	RetailerID string
+	From       string
+	To         string
+}
+
+func (filter Filter) Matches(stored receipt.Receipt) bool {
+	if filter.RetailerID != "" && stored.RetailerID != "" {
+		if stored.RetailerID != filter.RetailerID {
+			return false
+		}
+	} else if filter.Retailer != "" && !strings.EqualFold(strings.TrimSpace(stored.Retailer), strings.TrimSpace(filter.Retailer)) {
+		return false
+	}
+	if filter.From != "" && stored.PurchaseDate < filter.From {
+		return false
+	}
+	if filter.To != "" && stored.PurchaseDate > filter.To {
+		return false
+	}
+	return true
+}
+
+type MemoryStore struct {
+	mu       sync.RWMutex
+	receipts map[string]receipt.Receipt
+	order    []string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{receipts: make(map[string]receipt.Receipt)}
+}
+
+func (store *MemoryStore) Save(ctx context.Context, id string, saved receipt.Receipt) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, exists := store.receipts[id]; !exists {
+		store.order = append(store.order, id)
+	}
+	store.receipts[id] = saved
+	return nil
+}
+
+func (store *MemoryStore) Get(ctx context.Context, id string) (receipt.Receipt, bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	found, exists := store.receipts[id]
+	return found, exists, nil
+}
+
+func (store *MemoryStore) Count(ctx context.Context) (int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return len(store.receipts), nil
+}
+
+func (store *MemoryStore) List(ctx context.Context, filter Filter, each func(id string, stored receipt.Receipt) error) error {
+	store.mu.RLock()
+	var ids []string
+	var matched []receipt.Receipt
+	for _, id := range store.order {
+		if filter.Matches(store.receipts[id]) {
+			ids = append(ids, id)
+			matched = append(matched, store.receipts[id])
+		}
+	}
+	store.mu.RUnlock()
+
+	for index, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := each(id, matched[index])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *MemoryStore) Ping(ctx context.Context) error {
+	acquired := make(chan struct{})
+	go func() {
+		store.mu.RLock()
+		store.mu.RUnlock()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"receipt-processor/receipt"
+)
+
+func TestMemoryStoreSaveAndGet(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Save(ctx, "abc-123", receipt.Receipt{Retailer: "Walgreens"})
+	found, exists, err := store.Get(ctx, "abc-123")
+	if err != nil || !exists {
+		t.Errorf("Should find receipt abc-123 ... %v", err)
+	}
+	if found.Retailer != "Walgreens" {
+		t.Errorf("Should get back the saved receipt not %v", found)
+	}
+	_, exists, _ = store.Get(ctx, "missing")
+	if exists {
+		t.Errorf("Should not find a receipt that was never saved")
+	}
+	count, _ := store.Count(ctx)
+	if count != 1 {
+		t.Errorf("Should have 1 receipt not %d", count)
+	}
+	if store.Ping(ctx) != nil {
+		t.Errorf("Ping should succeed for a memory store")
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Save(ctx, "1", receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01"})
+	store.Save(ctx, "2", receipt.Receipt{Retailer: "Walgreens", PurchaseDate: "2022-01-02"})
+	store.Save(ctx, "3", receipt.Receipt{Retailer: "target", PurchaseDate: "2022-03-20"})
+	store.Save(ctx, "1", receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01"})
+
+	list := func(filter Filter) string {
+		var ids []string
+		store.List(ctx, filter, func(id string, stored receipt.Receipt) error {
+			ids = append(ids, id)
+			return nil
+		})
+		return strings.Join(ids, ",")
+	}
+	cases := []struct {
+		filter   Filter
+		expected string
+	}{
+		{Filter{}, "1,2,3"},
+		{Filter{Retailer: "TARGET"}, "1,3"},
+		{Filter{From: "2022-01-02"}, "2,3"},
+		{Filter{To: "2022-01-02"}, "1,2"},
+		{Filter{Retailer: "target", From: "2022-02-01", To: "2022-12-31"}, "3"},
+	}
+	for _, c := range cases {
+		if got := list(c.filter); got != c.expected {
+			t.Errorf("List(%+v) should return %s not %s", c.filter, c.expected, got)
+		}
+	}
+}